@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/umut/kr/internal/renamer"
@@ -14,17 +16,30 @@ var (
 )
 
 var moveCmd = &cobra.Command{
-	Use:   "move <file> <new.package.path>",
-	Short: "Move a Kotlin file to a new package",
-	Long: `Move a .kt file to a new package, updating:
+	Use:   "move <file|dir|glob>... <new.package.path>",
+	Short: "Move Kotlin files to a new package",
+	Long: `Move .kt files to a new package, updating:
   - The package declaration inside the file
-  - All import statements across the project
+  - All import statements across the project, for every top-level
+    declaration in the file and their nested classes and companions
+  - Fully-qualified references in code, annotations and KDoc, and an
+    explicit import in files that used the old package's wildcard import
+  - Imports for references that were same-package: in files of the old
+    package using the moved declarations, and in the moved file for what
+    it used from the old package (imports made redundant are removed)
   - The file's location on disk (to match standard src/main/kotlin layout)
 
+Several files, globs ("billing/*.kt", quoted or expanded by the shell) and
+directories (the .kt files directly inside) move as one batch: the project
+is scanned once, references between the moved files follow them, and no
+file is written unless every file can be.
+
 Examples:
   kr move UserService.kt com.example.newpackage --project ./src
-  kr move src/main/kotlin/com/example/UserService.kt com.example.util --project ./src --dry-run`,
-	Args: cobra.ExactArgs(2),
+  kr move src/main/kotlin/com/example/UserService.kt com.example.util --project ./src --dry-run
+  kr move src/main/kotlin/com/example/billing/*.kt com.example.payments --project .
+  kr move src/main/kotlin/com/example/billing com.example.payments --project .`,
+	Args: cobra.MinimumNArgs(2),
 	RunE: runMove,
 }
 
@@ -38,16 +53,22 @@ func init() {
 }
 
 func runMove(cmd *cobra.Command, args []string) error {
-	filePath := args[0]
-	newPackage := args[1]
+	newPackage := args[len(args)-1]
 
-	// Basic package name validation
-	if !isValidPackageName(newPackage) {
-		return fmt.Errorf("invalid package name: %q (expected e.g. com.example.mypackage)", newPackage)
+	if err := renamer.ValidatePackageName(newPackage); err != nil {
+		return err
+	}
+	if warning := renamer.NamingWarning("package", newPackage); warning != "" {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+
+	files, err := expandMoveArgs(args[:len(args)-1])
+	if err != nil {
+		return err
 	}
 
 	opts := renamer.MoveOptions{
-		FilePath:    filePath,
+		FilePaths:   files,
 		NewPackage:  newPackage,
 		ProjectRoot: moveProject,
 		DryRun:      moveDryRun,
@@ -62,48 +83,55 @@ func runMove(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func isValidPackageName(pkg string) bool {
-	if pkg == "" {
-		return false
+// expandMoveArgs turns the file arguments of kr move into .kt files: globs
+// are expanded and directories contribute the .kt files directly inside
+// them.  A file named twice is kept once.
+func expandMoveArgs(args []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
 	}
-	// Each segment must be a valid identifier
-	parts := splitDot(pkg)
-	for _, p := range parts {
-		if p == "" {
-			return false
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(arg); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
 		}
-		for i, c := range p {
-			if i == 0 {
-				if !isLetter(c) && c != '_' {
-					return false
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				if strings.HasSuffix(path, ".kt") || len(matches) == 1 {
+					add(path)
 				}
-			} else {
-				if !isLetter(c) && !isDigit(c) && c != '_' {
-					return false
+				continue
+			}
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			found := false
+			for _, e := range entries {
+				if !e.IsDir() && strings.HasSuffix(e.Name(), ".kt") {
+					add(filepath.Join(path, e.Name()))
+					found = true
 				}
 			}
+			if !found && len(matches) == 1 {
+				return nil, fmt.Errorf("no .kt files in %s", path)
+			}
 		}
 	}
-	return true
-}
-
-func splitDot(s string) []string {
-	var parts []string
-	start := 0
-	for i, c := range s {
-		if c == '.' {
-			parts = append(parts, s[start:i])
-			start = i + 1
-		}
-	}
-	parts = append(parts, s[start:])
-	return parts
-}
-
-func isLetter(c rune) bool {
-	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
-}
-
-func isDigit(c rune) bool {
-	return c >= '0' && c <= '9'
+	return files, nil
 }
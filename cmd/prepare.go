@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	prepareAt      string
+	prepareProject string
+)
+
+var prepareRenameCmd = &cobra.Command{
+	Use:   "prepare-rename --at <file:line:column>",
+	Short: "Show the symbol a position-based rename would target",
+	Long: `Resolve the identifier at file:line:column (1-based) to the symbol it
+refers to, without renaming anything.  Prints the symbol type, the
+identifier's range, the declaration's position and the class, function or
+fully-qualified name that scope it, so an editor or script can confirm the
+target before running kr rename --at.
+
+Examples:
+  kr prepare-rename --at src/main/kotlin/com/example/UserService.kt:42:17 --project ./src
+  kr prepare-rename --at Box.kt:3:12`,
+	Args: cobra.NoArgs,
+	RunE: runPrepareRename,
+}
+
+func init() {
+	prepareRenameCmd.Flags().StringVar(&prepareAt, "at", "",
+		"Position of the identifier, file:line:column (1-based), e.g. UserService.kt:42:17")
+	prepareRenameCmd.Flags().StringVar(&prepareProject, "project", "",
+		"Project root — resolves references to declarations in other files")
+
+	_ = prepareRenameCmd.MarkFlagRequired("at")
+}
+
+func runPrepareRename(cmd *cobra.Command, args []string) error {
+	sym, err := symbolAt(cmd, prepareAt, prepareProject)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	fmt.Fprintf(w, "kind:        %s\n", sym.Kind)
+	fmt.Fprintf(w, "name:        %s\n", sym.Name)
+	fmt.Fprintf(w, "range:       %s-%d:%d\n", sym.Start, sym.End.Line, sym.End.Column)
+	fmt.Fprintf(w, "declaration: %s\n", sym.Decl)
+	if sym.ClassName != "" {
+		label := "class:      "
+		if sym.Kind == "extension" {
+			label = "receiver:   "
+		}
+		fmt.Fprintf(w, "%s %s\n", label, sym.ClassName)
+	}
+	if sym.Function != "" {
+		fmt.Fprintf(w, "function:    %s\n", sym.Function)
+	}
+	if sym.FQN != "" {
+		fmt.Fprintf(w, "fqn:         %s\n", sym.FQN)
+	}
+	scope := "project"
+	if sym.FileLocal || prepareProject == "" {
+		scope = "file"
+	}
+	fmt.Fprintf(w, "scope:       %s\n", scope)
+	project := ""
+	if prepareProject != "" {
+		project = " --project " + prepareProject
+	}
+	fmt.Fprintf(w, "rename:      kr rename --at %s <new>%s\n", prepareAt, project)
+	return nil
+}
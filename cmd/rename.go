@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -10,52 +12,204 @@ import (
 )
 
 var (
-	renameType    string
-	renameFile    string
-	renameProject string
-	renameClass   string
-	renameDryRun  bool
+	renameType            string
+	renameFile            string
+	renameProject         string
+	renameClass           string
+	renameFunction        string
+	renameSignature       string
+	renameReceiver        string
+	renameFQN             string
+	renameLine            int
+	renameColumn          int // set by --at, to pick one of several locals on renameLine
+	renameAt              string
+	renameDryRun          bool
+	renameForce           bool
+	renameIncludeComments bool
+	renameIncludeStrings  bool
+	renameTestNames       bool
 )
 
 var renameCmd = &cobra.Command{
-	Use:   "rename <old> <new>",
+	Use:   "rename <old> <new> | rename --at <file:line:column> <new>",
 	Short: "Rename a Kotlin symbol across the project",
 	Long: `Rename a Kotlin symbol with syntax-aware, word-boundary matching.
 
 Supported symbol types (--type flag):
+  auto        (default) the kind of the declaration named <old> in the
+              scanned files, scoped to its class, function or package;
+              when it is declared as several kinds, in several packages
+              or as several symbols, the candidates are listed instead
   class       class, interface, object declarations + all usages
   interface   same as class
   object      same as class
   method      fun declarations, call sites, and method references
   property    val/var declarations and member access
-  parameter   parameter names within function signatures and bodies
+  parameter   parameter names within function signatures and bodies, and
+              named arguments at call sites
+  extension   extension fun/val declarations, calls on receivers of that
+              type, and imports
+  top-level   top-level fun/val declarations, imports, qualified uses and
+              uses in files of the same package
+  enum-entry  entries of the enum class given by --class, qualified uses,
+              when branches and imports
+  typealias   typealias declarations, type uses and imports
+  type-parameter
+              type parameters within their declaring class, function or
+              typealias: bounds, where clauses, signature and body
+  local       local val/var, destructured, for-loop and lambda parameter
+              variables and the references bound to them; renaming "it"
+              names a lambda's implicit parameter
+
+With --class, a method rename only touches calls whose receiver is inferred
+to be that class; calls with an unresolvable receiver are reported, not
+renamed.  Property renames are always scoped to the declaring class — the
+one given by --class, or the only class that declares the property.
+
+Scoped method and property renames cover the member's whole override
+family: renaming an override is escalated to the declaration it overrides,
+and every override, super call and subclass receiver follows along.
+
+Parameter renames cover every function and constructor declaring the
+parameter, narrowed by --function and --class (which also takes in the
+overrides of that class's functions); a constructor counts as a function
+named after its class.  Constructor parameters are followed into init
+blocks, property initializers, this(...) delegation and data-class copy()
+calls, and val/var parameters into their property accesses.  With
+--project, named arguments (userId = 42) at call sites in every file are
+renamed too; call sites that can't be matched to one function by name,
+receiver type and argument count are reported.
+
+Use --signature to rename one overload of a method: its declaration (and
+overrides) and the calls whose arguments fit only that overload.  Calls
+that fit several overloads are listed instead of renamed.
+
+Extension renames are scoped to one receiver type — the one given by
+--receiver, or the only type an extension of that name is declared on.
+Calls are renamed when their receiver is inferred to be that type or a
+subtype; unresolvable receivers are reported.
+
+Enum entry renames need --class naming the enum.  Status.valueOf("PENDING")
+and enumValueOf<Status>("PENDING") calls are reported, not renamed;
+entries and values() are unaffected.
+
+Type parameter renames cover every declaration with a type parameter of
+that name, narrowed by --function and --class.  Nested declarations
+redeclaring the name, and nested (non-inner) classes, are left alone.
+
+Local renames need --function (the enclosing function) or --line with
+--file (a line declaring or using the local).  Only references resolving
+to that declaration are renamed; shadowing locals in nested blocks and
+lambdas, member accesses and named arguments are left alone.  Lambda
+parameters ({ user -> }, { (k, v) -> }) are locals too, and renaming "it"
+turns the implicit parameter of the lambdas on --line (or in --function)
+into a named one, leaving the it of nested lambdas alone.
+
+Use --fqn to rename one of several same-named classes: each file's package,
+imports, aliases and wildcard imports decide whether a given User refers to
+the target.  For --type top-level and typealias, --fqn names the
+declaration's package (e.g. com.example.util.formatDate) and can be omitted
+when only one package declares the name.
+
+Use --at file:line:column instead of <old> and the scoping flags to rename
+whatever the identifier at that position refers to — a declaration or any
+use of it — the way an editor's rename action does: the symbol type, class,
+function and package are worked out from the code.  Locals, type parameters
+and local functions are renamed in that file only; other symbols in the
+whole --project when given.  kr prepare-rename shows what --at resolves to.
+
+Before any file is written, the rename is checked for conflicts: a
+declaration of the new name in the same package, class or scope, an
+overload with the same parameter types, a local or parameter that would
+shadow the renamed symbol (or be shadowed by it), and files that already
+import a different symbol with the new name.  Conflicts abort the rename
+unless --force is given.
+
+Occurrences inside comments and string literals are left untouched unless
+--include-comments / --include-strings is given.  Identifiers referenced
+from string templates ("$userId") are code and are always renamed.
+
+Names quoted in backticks are single identifiers: <old> and <new> may be
+quoted (` + "`in`" + `, ` + "`creates a user`" + `), and a quoted name is never matched by a
+word inside it.  With --include-test-names, a class rename also rewrites
+the class name inside quoted function names such as
+fun ` + "`creates a User when email is valid`" + `().
 
 Examples:
   kr rename --type class User UserAccount --project ./src
+  kr rename --type auto UserRepository UserStore --project ./src
   kr rename --type method calculateTotal computeTotal --project ./src
   kr rename --type method calculateTotal computeTotal --file CartService.kt
+  kr rename --type method find findById --project ./src --class UserService --signature "(Long)"
   kr rename --type property userId accountId --file UserService.kt --class UserService
-  kr rename --type parameter userId accountId --file UserService.kt`,
-	Args: cobra.ExactArgs(2),
+  kr rename --type parameter userId accountId --file UserService.kt
+  kr rename --type extension toSlug slugify --project ./src --receiver String
+  kr rename --type top-level formatDate formatIsoDate --project ./src --fqn com.example.util.formatDate
+  kr rename --type enum-entry PENDING AWAITING --project ./src --class Status
+  kr rename --type typealias UserId AccountId --project ./src
+  kr rename --type type-parameter T TItem --file Box.kt --class Box
+  kr rename --type local total subtotal --file CartService.kt --line 42
+  kr rename --type local it user --file UserService.kt --line 17
+  kr rename --type parameter userId accountId --project ./src --function createUser
+  kr rename --type parameter customerId clientId --project ./src --function Invoice
+  kr rename --type class User Account --project ./src --fqn com.example.User
+  kr rename --type class User Account --project ./src --include-comments
+  kr rename --type class User Account --project ./src --include-test-names
+  kr rename --at src/main/kotlin/com/example/UserService.kt:42:17 findById --project ./src`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if renameAt != "" {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
 	RunE: runRename,
 }
 
 func init() {
-	renameCmd.Flags().StringVar(&renameType, "type", "class",
-		"Symbol type: class, interface, object, method, property, parameter")
+	renameCmd.Flags().StringVar(&renameType, "type", "auto",
+		"Symbol type: auto, class, interface, object, method, property, parameter, extension, top-level, enum-entry, typealias, type-parameter, local")
 	renameCmd.Flags().StringVar(&renameFile, "file", "",
 		"Restrict to a single file")
 	renameCmd.Flags().StringVar(&renameProject, "project", "",
 		"Project root — scans all .kt files recursively")
 	renameCmd.Flags().StringVar(&renameClass, "class", "",
-		"(method/property) Scope rename to a specific class name")
+		"(method/property/parameter/enum-entry/type-parameter) Scope rename to a specific class name")
+	renameCmd.Flags().StringVar(&renameFunction, "function", "",
+		"(parameter/type-parameter/local) Only rename parameters or locals of functions with this name")
+	renameCmd.Flags().IntVar(&renameLine, "line", 0,
+		"(local) Line of --file that declares or uses the local")
+	renameCmd.Flags().StringVar(&renameSignature, "signature", "",
+		`(method) Parameter types of the overload to rename, e.g. "(Long, String)"`)
+	renameCmd.Flags().StringVar(&renameReceiver, "receiver", "",
+		"(extension) Receiver type of the extension, e.g. String")
+	renameCmd.Flags().StringVar(&renameFQN, "fqn", "",
+		"(class/interface/object/top-level/typealias) Fully-qualified name of the target, e.g. com.example.User")
+	renameCmd.Flags().StringVar(&renameAt, "at", "",
+		"Rename the symbol at file:line:column (1-based) instead of naming it, e.g. UserService.kt:42:17")
 	renameCmd.Flags().BoolVar(&renameDryRun, "dry-run", false,
 		"Preview changes without writing files")
+	renameCmd.Flags().BoolVar(&renameForce, "force", false,
+		"Rename even if conflicts with existing declarations or imports are found")
+	renameCmd.Flags().BoolVar(&renameIncludeComments, "include-comments", false,
+		"Also rename matches inside // and /* */ comments")
+	renameCmd.Flags().BoolVar(&renameIncludeStrings, "include-strings", false,
+		"Also rename matches inside string and char literals")
+	renameCmd.Flags().BoolVar(&renameTestNames, "include-test-names", false,
+		"(class/interface/object) Also rename the word inside backtick-quoted function names, e.g. `creates a User`")
 }
 
 func runRename(cmd *cobra.Command, args []string) error {
-	oldName := args[0]
-	newName := args[1]
+	var oldName, newName string
+	if renameAt != "" {
+		sym, err := symbolAt(cmd, renameAt, renameProject)
+		if err != nil {
+			return err
+		}
+		useSymbol(sym)
+		oldName, newName = sym.Name, args[0]
+	} else {
+		oldName, newName = args[0], args[1]
+	}
 
 	// ── validation ────────────────────────────────────────────────────────────
 	if err := renamer.ValidateIdentifier(oldName); err != nil {
@@ -64,16 +218,71 @@ func runRename(cmd *cobra.Command, args []string) error {
 	if err := renamer.ValidateIdentifier(newName); err != nil {
 		return err
 	}
+	oldName = renamer.Unquote(oldName)
+	if renameFile == "" && renameProject == "" {
+		return fmt.Errorf("provide at least one of --file or --project")
+	}
 
 	symType := strings.ToLower(renameType)
+	if symType == "auto" {
+		var err error
+		if symType, err = detectType(oldName); err != nil {
+			return err
+		}
+	}
 	switch symType {
-	case "class", "interface", "object", "method", "property", "parameter":
+	case "class", "interface", "object", "method", "property", "parameter", "extension", "top-level", "enum-entry",
+		"typealias", "type-parameter", "local":
 	default:
-		return fmt.Errorf("unknown --type %q; use: class, interface, object, method, property, parameter", renameType)
+		return fmt.Errorf("unknown --type %q; use: auto, class, interface, object, method, property, parameter, extension, top-level, enum-entry, typealias, type-parameter, local", renameType)
+	}
+	if err := renamer.ValidateName(symType, newName); err != nil {
+		return err
+	}
+	if symType == "enum-entry" && renameClass == "" {
+		return fmt.Errorf("--type enum-entry requires --class naming the enum class")
 	}
 
-	if renameFile == "" && renameProject == "" {
-		return fmt.Errorf("provide at least one of --file or --project")
+	if renameFQN != "" {
+		switch symType {
+		case "class", "interface", "object", "top-level", "typealias":
+		default:
+			return fmt.Errorf("--fqn applies only to --type class, interface, object, top-level or typealias")
+		}
+		if !strings.HasSuffix("."+renameFQN, "."+oldName) {
+			return fmt.Errorf("--fqn %q does not end in %q", renameFQN, oldName)
+		}
+	}
+	if renameFunction != "" && symType != "parameter" && symType != "type-parameter" && symType != "local" {
+		return fmt.Errorf("--function applies only to --type parameter, type-parameter or local")
+	}
+	if renameLine != 0 {
+		if symType != "local" {
+			return fmt.Errorf("--line applies only to --type local")
+		}
+		if renameFile == "" || renameLine < 0 {
+			return fmt.Errorf("--line needs --file and a positive line number")
+		}
+	}
+	if symType == "local" && renameFunction == "" && renameLine == 0 {
+		return fmt.Errorf("--type local requires --function or --line to locate the variable")
+	}
+	if renameReceiver != "" && symType != "extension" {
+		return fmt.Errorf("--receiver applies only to --type extension")
+	}
+	if renameTestNames && symType != "class" && symType != "interface" && symType != "object" {
+		return fmt.Errorf("--include-test-names applies only to --type class, interface or object")
+	}
+	var signature renamer.Signature
+	if renameSignature != "" {
+		if symType != "method" {
+			return fmt.Errorf("--signature applies only to --type method")
+		}
+		sig, err := renamer.ParseSignature(renameSignature)
+		if err != nil {
+			return err
+		}
+		signature = sig
 	}
 
 	// ── collect files ─────────────────────────────────────────────────────────
@@ -89,8 +298,97 @@ func runRename(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no .kt files found")
 	}
 
+	// ── resolve the declaration ───────────────────────────────────────────────
+	var index *renamer.Index
+	className := renameClass
+	fqn := renameFQN
+	switch {
+	case symType == "property" || (symType == "method" && (className != "" || signature != nil)):
+		if index, err = buildIndex(files); err != nil {
+			return err
+		}
+		if symType == "property" && className == "" {
+			if className, err = uniquePropertyOwner(index, oldName); err != nil {
+				return err
+			}
+		}
+		if className != "" {
+			if className, err = resolveMember(index, className, oldName, symType == "property"); err != nil {
+				return err
+			}
+		}
+		if signature != nil {
+			if err = checkOverload(index, className, oldName, signature); err != nil {
+				return err
+			}
+		}
+	case symType == "extension":
+		if index, err = buildIndex(files); err != nil {
+			return err
+		}
+		if className, err = extensionReceiver(index, oldName); err != nil {
+			return err
+		}
+		fmt.Printf("Renaming %s.%s\n", className, oldName)
+	case symType == "top-level":
+		if index, err = buildIndex(files); err != nil {
+			return err
+		}
+		if fqn, err = uniqueFQN(index.TopLevelDeclarations(oldName), oldName, "top-level function or property"); err != nil {
+			return err
+		}
+		fmt.Printf("Renaming %s\n", fqn)
+	case symType == "typealias":
+		if index, err = buildIndex(files); err != nil {
+			return err
+		}
+		if fqn, err = uniqueFQN(index.TypeAliases(oldName), oldName, "typealias"); err != nil {
+			return err
+		}
+		fmt.Printf("Renaming %s\n", fqn)
+	case symType == "enum-entry":
+		if index, err = buildIndex(files); err != nil {
+			return err
+		}
+		if err = checkEnumEntry(index, className, oldName); err != nil {
+			return err
+		}
+		fmt.Printf("Renaming %s.%s\n", className, oldName)
+	case symType == "parameter":
+		if index, err = buildIndex(files); err != nil {
+			return err
+		}
+		// --function naming the class itself targets its constructors
+		if className != "" && renameFunction != "" && renameFunction != className {
+			if className, err = resolveMember(index, className, renameFunction, false); err != nil {
+				return err
+			}
+		}
+	}
+
 	// ── build rename function ─────────────────────────────────────────────────
-	renameFn := buildRenameFn(symType, oldName, newName)
+	r := buildRenamer(symType, className, fqn, signature, index)
+	renameFn := renamer.NewRenameFunc(r, oldName, newName)
+
+	// ── check naming and conflicts ────────────────────────────────────────────
+	if index == nil {
+		if index, err = buildIndex(files); err != nil {
+			return err
+		}
+	}
+	convention := symType
+	if (symType == "property" || symType == "top-level") && index.IsConstant(className, oldName) {
+		convention = "const"
+	}
+	if warning := renamer.NamingWarning(convention, newName); warning != "" {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	if conflicts := renamer.FindConflicts(r, index, files, oldName, newName); len(conflicts) > 0 {
+		renamer.PrintConflicts(os.Stdout, conflicts, renameForce)
+		if !renameForce {
+			return fmt.Errorf("rename aborted: %d conflict(s) found", len(conflicts))
+		}
+	}
 
 	// ── apply ─────────────────────────────────────────────────────────────────
 	results, err := renamer.ApplyToFiles(files, renameDryRun, renameFn)
@@ -102,35 +400,338 @@ func runRename(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// buildRenameFn returns a function that renames oldName→newName according to
-// the symbol type.
-func buildRenameFn(symType, oldName, newName string) func(string) (string, int) {
-	switch symType {
-	case "class", "interface", "object":
-		r := &renamer.ClassRenamer{}
-		return func(content string) (string, int) {
-			return r.Rename(content, oldName, newName)
+// symbolAt resolves --at file:line:column against the files under project,
+// or just that file.  The scoping flags it replaces are rejected.
+func symbolAt(cmd *cobra.Command, at, project string) (*renamer.Symbol, error) {
+	for _, flag := range []string{"type", "file", "class", "function", "line", "signature", "receiver", "fqn"} {
+		if f := cmd.Flags().Lookup(flag); f != nil && f.Changed {
+			return nil, fmt.Errorf("--at identifies the symbol by itself; drop --%s", flag)
 		}
+	}
+	path, line, column, err := parsePosition(at)
+	if err != nil {
+		return nil, err
+	}
+	file, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(file); err != nil {
+		return nil, fmt.Errorf("--at: %w", err)
+	}
 
-	case "method":
-		r := &renamer.MethodRenamer{ClassName: renameClass}
-		return func(content string) (string, int) {
-			return r.Rename(content, oldName, newName)
+	files := []string{file}
+	if project != "" {
+		all, err := renamer.CollectKotlinFiles(renamer.ScanOptions{ProjectRoot: project})
+		if err != nil {
+			return nil, fmt.Errorf("scanning files: %w", err)
 		}
+		for _, f := range all {
+			if f != file {
+				files = append(files, f)
+			}
+		}
+	}
+	index, err := renamer.BuildIndex(files)
+	if err != nil {
+		return nil, err
+	}
+	return index.SymbolAt(file, line, column)
+}
 
-	case "property":
-		r := &renamer.PropertyRenamer{ClassName: renameClass}
-		return func(content string) (string, int) {
-			return r.Rename(content, oldName, newName)
+// detectType implements --type auto: it returns the kind of the
+// declarations called name in the scanned files, narrowed by --class and
+// --fqn, when they agree on one kind and package, and lists them otherwise.
+// A single local is located for the rename as --at would.
+func detectType(name string) (string, error) {
+	files, err := renamer.CollectKotlinFiles(renamer.ScanOptions{ProjectRoot: renameProject, SingleFile: renameFile})
+	if err != nil {
+		return "", fmt.Errorf("scanning files: %w", err)
+	}
+	index, err := buildIndex(files)
+	if err != nil {
+		return "", err
+	}
+
+	var decls []*renamer.Symbol
+	kinds := make(map[string]bool)
+	pkgs := make(map[string]bool)
+	for _, d := range index.Declarations(name) {
+		if d.FileLocal && renameFile != "" && d.Decl.Path != files[0] ||
+			renameClass != "" && d.ClassName != renameClass || renameFQN != "" && d.FQN != renameFQN ||
+			renameFunction != "" && d.Function != "" && d.Function != renameFunction {
+			continue
 		}
+		decls = append(decls, d)
+		kinds[d.Kind] = true
+		pkgs[d.Package] = true
+	}
+	if len(decls) == 0 {
+		return "", fmt.Errorf("no declaration named %q found in the scanned files; give --type explicitly", name)
+	}
+	if len(kinds) > 1 || len(pkgs) > 1 {
+		return "", candidatesError(decls, "%q is declared as %d kind(s) in %d package(s); choose one with --type (plus --fqn or --class), or use --at:",
+			name, len(kinds), len(pkgs))
+	}
 
-	case "parameter":
-		r := &renamer.ParameterRenamer{}
-		return func(content string) (string, int) {
-			return r.Rename(content, oldName, newName)
+	// locals already narrowed by --function or --line are left to the
+	// local renamer; anything else must be one symbol, which then scopes
+	// the rename the way --at does
+	d := decls[0]
+	if d.Kind != "local" || renameFunction == "" && renameLine == 0 {
+		targets := make(map[string]bool)
+		for _, d := range decls {
+			targets[symbolKey(d)] = true
+		}
+		if len(targets) > 1 {
+			return "", candidatesError(decls, "%q names %d different %s declarations; choose one with --class, --function or --line, or use --at:",
+				name, len(targets), d.Kind)
+		}
+		useSymbol(d)
+	}
+	fmt.Printf("Detected --type %s\n", d.Kind)
+	return d.Kind, nil
+}
+
+// symbolKey identifies the symbol a declaration belongs to: overloads of a
+// method, or the declarations of one class across expect/actual files,
+// share a key, while locals and local functions are each their own.
+func symbolKey(d *renamer.Symbol) string {
+	if d.Kind == "local" || d.FileLocal && d.Kind == "method" {
+		return d.Decl.String()
+	}
+	return strings.Join([]string{d.Kind, d.ClassName, d.Function, d.FQN}, "|")
+}
+
+// candidatesError lists decls as the candidates to choose from, after the
+// message format describes.
+func candidatesError(decls []*renamer.Symbol, format string, args ...any) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, format, args...)
+	for _, d := range decls {
+		fmt.Fprintf(&b, "\n  %-14s %-40s %s", d.Kind, describeSymbol(d), d.Decl)
+	}
+	return fmt.Errorf("%s", b.String())
+}
+
+// describeSymbol names sym for a list of candidates, e.g. User.greet or
+// com.example.formatDate.
+func describeSymbol(sym *renamer.Symbol) string {
+	switch {
+	case sym.FQN != "":
+		return sym.FQN
+	case sym.Kind == "parameter" || sym.Kind == "type-parameter" && sym.Function != "":
+		owner := sym.Function
+		if sym.ClassName != "" && sym.ClassName != sym.Function {
+			owner = sym.ClassName + "." + owner
+		}
+		return owner + "(" + sym.Name + ")"
+	case sym.ClassName != "":
+		return sym.ClassName + "." + sym.Name
+	case sym.Package != "":
+		return sym.Name + " in " + sym.Package
+	}
+	return sym.Name
+}
+
+// parsePosition splits file:line:column.  The file may itself contain
+// colons, as in C:\src\User.kt:3:7.
+func parsePosition(at string) (path string, line, column int, err error) {
+	bad := fmt.Errorf("invalid position %q (expected file:line:column, e.g. UserService.kt:42:17)", at)
+	k := strings.LastIndexByte(at, ':')
+	if k < 0 {
+		return "", 0, 0, bad
+	}
+	j := strings.LastIndexByte(at[:k], ':')
+	if j <= 0 {
+		return "", 0, 0, bad
+	}
+	line, err1 := strconv.Atoi(at[j+1 : k])
+	column, err2 := strconv.Atoi(at[k+1:])
+	if err1 != nil || err2 != nil || line < 1 || column < 1 {
+		return "", 0, 0, bad
+	}
+	return at[:j], line, column, nil
+}
+
+// useSymbol points the rename flags at sym, as if they had been given.
+func useSymbol(sym *renamer.Symbol) {
+	renameType = sym.Kind
+	switch sym.Kind {
+	case "extension":
+		renameReceiver = sym.ClassName
+	case "local":
+		renameLine, renameColumn = sym.Decl.Line, sym.Decl.Column
+	case "parameter", "type-parameter":
+		renameClass, renameFunction = sym.ClassName, sym.Function
+	default:
+		renameClass = sym.ClassName
+	}
+	renameFQN = sym.FQN
+	if sym.FileLocal || renameProject == "" {
+		renameFile = sym.Decl.Path
+	}
+}
+
+// buildIndex indexes the declarations visible to the rename: the whole
+// project when --project is given (so subclasses and callers in other files
+// are known), otherwise just the files being renamed.
+func buildIndex(files []string) (*renamer.Index, error) {
+	if renameProject != "" && renameFile != "" {
+		all, err := renamer.CollectKotlinFiles(renamer.ScanOptions{ProjectRoot: renameProject})
+		if err != nil {
+			return nil, fmt.Errorf("scanning files: %w", err)
+		}
+		files = all
+	}
+	return renamer.BuildIndex(files)
+}
+
+// uniquePropertyOwner finds the class declaring property name when --class
+// was not given.  It returns "" when no class declares it (a top-level or
+// local property), and an error when several do.
+func uniquePropertyOwner(index *renamer.Index, name string) (string, error) {
+	owners := index.PropertyOwners(name)
+	switch len(owners) {
+	case 0:
+		return "", nil
+	case 1:
+		return owners[0], nil
+	}
+	return "", fmt.Errorf("property %q is declared in %d classes (%s); choose one with --class",
+		name, len(owners), strings.Join(owners, ", "))
+}
+
+// resolveMember escalates a member of className to the root declaration of
+// its override family and reports the classes the rename will touch.  A
+// class the index does not know is returned unchanged.
+func resolveMember(index *renamer.Index, className, name string, property bool) (string, error) {
+	if !index.HasClass(className) {
+		return className, nil
+	}
+	fam, err := index.MemberFamily(className, name, property)
+	if err != nil {
+		return "", err
+	}
+
+	root := fam.Roots[0]
+	if root != className {
+		fmt.Printf("%s.%s overrides %s.%s — renaming the whole override family\n", className, name, root, name)
+	}
+	if len(fam.Declared) > 1 {
+		fmt.Printf("Renaming %s.%s — declared in %s\n", root, name, strings.Join(fam.Declared, ", "))
+	} else {
+		fmt.Printf("Renaming %s.%s\n", root, name)
+	}
+	return root, nil
+}
+
+// extensionReceiver returns the receiver type of the extension called name:
+// --receiver, or the only type such an extension is declared on.
+func extensionReceiver(index *renamer.Index, name string) (string, error) {
+	receivers := index.ExtensionReceivers(name)
+	if renameReceiver != "" {
+		for _, r := range receivers {
+			if r == renameReceiver {
+				return r, nil
+			}
 		}
+		return "", fmt.Errorf("no extension %s.%s found", renameReceiver, name)
+	}
+	switch len(receivers) {
+	case 0:
+		return "", fmt.Errorf("no extension named %q found", name)
+	case 1:
+		return receivers[0], nil
 	}
+	return "", fmt.Errorf("extension %q is declared on %d receiver types (%s); choose one with --receiver",
+		name, len(receivers), strings.Join(receivers, ", "))
+}
+
+// uniqueFQN picks the declaration to rename among decls, the
+// fully-qualified names of the declarations called name: --fqn, or the only
+// one.  what describes the kind of declaration in errors.
+func uniqueFQN(decls []string, name, what string) (string, error) {
+	if renameFQN != "" {
+		for _, d := range decls {
+			if d == renameFQN {
+				return d, nil
+			}
+		}
+		return "", fmt.Errorf("no %s %s found", what, renameFQN)
+	}
+	switch len(decls) {
+	case 0:
+		return "", fmt.Errorf("no %s named %q found", what, name)
+	case 1:
+		return decls[0], nil
+	}
+	return "", fmt.Errorf("%s %q is declared in %d packages (%s); choose one with --fqn",
+		what, name, len(decls), strings.Join(decls, ", "))
+}
 
-	// unreachable after validation
-	return func(content string) (string, int) { return content, 0 }
+// checkEnumEntry makes sure className is an enum class with an entry called
+// name.
+func checkEnumEntry(index *renamer.Index, className, name string) error {
+	entries, ok := index.EnumEntries(className)
+	if !ok {
+		return fmt.Errorf("enum class %s not found", className)
+	}
+	for _, e := range entries {
+		if e == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("enum class %s has no entry %s; entries: %s", className, name, strings.Join(entries, ", "))
+}
+
+// checkOverload makes sure some function called name (in className, if
+// given) declares the parameter types sig.
+func checkOverload(index *renamer.Index, className, name string, sig renamer.Signature) error {
+	overloads := index.Overloads(className, name)
+	var declared []string
+	for _, o := range overloads {
+		if o.String() == sig.String() {
+			return nil
+		}
+		declared = append(declared, name+o.String())
+	}
+	target := name
+	if className != "" {
+		target = className + "." + name
+	}
+	if len(declared) == 0 {
+		return fmt.Errorf("no declaration of %s found", target)
+	}
+	return fmt.Errorf("no overload of %s matches %s; declared: %s", target, sig, strings.Join(declared, ", "))
+}
+
+// buildRenamer returns the renamer for the symbol type.
+func buildRenamer(symType, className, fqn string, signature renamer.Signature, index *renamer.Index) renamer.Renamer {
+	match := renamer.MatchOptions{
+		IncludeComments: renameIncludeComments,
+		IncludeStrings:  renameIncludeStrings,
+	}
+
+	switch symType {
+	case "method":
+		return &renamer.MethodRenamer{MatchOptions: match, ClassName: className, Signature: signature, Index: index}
+	case "property":
+		return &renamer.PropertyRenamer{MatchOptions: match, ClassName: className, Index: index}
+	case "top-level":
+		return &renamer.TopLevelRenamer{MatchOptions: match, FQN: fqn, Index: index}
+	case "typealias":
+		return &renamer.TypeAliasRenamer{MatchOptions: match, FQN: fqn}
+	case "type-parameter":
+		return &renamer.TypeParameterRenamer{MatchOptions: match, Function: renameFunction, ClassName: className}
+	case "local":
+		return &renamer.LocalRenamer{MatchOptions: match, Function: renameFunction, Line: renameLine, Column: renameColumn}
+	case "enum-entry":
+		return &renamer.EnumEntryRenamer{MatchOptions: match, ClassName: className}
+	case "extension":
+		return &renamer.ExtensionRenamer{MatchOptions: match, Receiver: className, Index: index}
+	case "parameter":
+		return &renamer.ParameterRenamer{MatchOptions: match, Function: renameFunction, ClassName: className, Index: index}
+	}
+	return &renamer.ClassRenamer{MatchOptions: match, FQN: fqn, TestNames: renameTestNames}
 }
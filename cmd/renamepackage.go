@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/umut/kr/internal/renamer"
+)
+
+var (
+	renamePackageProject string
+	renamePackageDryRun  bool
+)
+
+var renamePackageCmd = &cobra.Command{
+	Use:   "rename-package <old.package> <new.package>",
+	Short: "Rename a package and its subpackages",
+	Long: `Rename a package prefix recursively.  Every file declaring the package
+or one of its subpackages moves to the same package under the new name
+(com.old.api becomes com.new.api), exactly as kr move would move it:
+  - The package declaration inside each file
+  - All imports and fully-qualified references across the project,
+    including wildcard imports of the renamed packages
+  - The files' location on disk, under each source root
+    (src/main/kotlin, src/test/kotlin, ...), with old directories left
+    empty removed
+
+Files are renamed as one batch: no file is written unless every file can be.
+
+Examples:
+  kr rename-package com.example.old com.example.new --project .
+  kr rename-package com.example.billing com.example.payments --project . --dry-run`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRenamePackage,
+}
+
+func init() {
+	renamePackageCmd.Flags().StringVar(&renamePackageProject, "project", "",
+		"Project root — scanned for the package's files and for import rewriting")
+	renamePackageCmd.Flags().BoolVar(&renamePackageDryRun, "dry-run", false,
+		"Preview changes without writing or moving files")
+
+	_ = renamePackageCmd.MarkFlagRequired("project")
+}
+
+func runRenamePackage(cmd *cobra.Command, args []string) error {
+	oldPackage, newPackage := args[0], args[1]
+
+	for _, pkg := range args {
+		if err := renamer.ValidatePackageName(pkg); err != nil {
+			return err
+		}
+	}
+	if warning := renamer.NamingWarning("package", newPackage); warning != "" {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+
+	opts := renamer.PackageRenameOptions{
+		OldPackage:  oldPackage,
+		NewPackage:  newPackage,
+		ProjectRoot: renamePackageProject,
+		DryRun:      renamePackageDryRun,
+	}
+
+	result, err := renamer.RenamePackage(opts)
+	if err != nil {
+		return err
+	}
+
+	renamer.PrintMoveResult(os.Stdout, result, renamePackageDryRun)
+	return nil
+}
@@ -14,9 +14,14 @@ rename symbols without touching substrings (e.g. renaming User does
 NOT affect UserService).
 
 Commands:
-  rename    Rename a class, interface, object, method, property, or parameter
-  move      Move a .kt file to a new package, updating all imports
-  setup     Install AI editor integrations (Claude Code, Cursor)`,
+  rename          Rename a class, interface, object, method, property, parameter,
+                  extension, top-level declaration, enum entry, typealias,
+                  type parameter or local; the kind is detected from the
+                  declaration unless --type is given
+  prepare-rename  Show what the identifier at a file:line:column refers to
+  move            Move .kt files, globs or directories to a new package
+  rename-package  Rename a package and its subpackages, moving their files
+  setup           Install AI editor integrations (Claude Code, Cursor)`,
 	SilenceUsage: true,
 }
 
@@ -32,6 +37,8 @@ func SetVersion(v string) {
 
 func init() {
 	rootCmd.AddCommand(renameCmd)
+	rootCmd.AddCommand(prepareRenameCmd)
 	rootCmd.AddCommand(moveCmd)
+	rootCmd.AddCommand(renamePackageCmd)
 	rootCmd.AddCommand(setupCmd)
 }
@@ -3,9 +3,52 @@ package renamer
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
+// Renamer rewrites every occurrence of one symbol in a single file's content.
+// oldName is given without backticks, as Unquote returns it; newName is
+// written as given, so it must be quoted if it needs to be (occurrences
+// already in backticks get it without its own).
+type Renamer interface {
+	Rename(content, oldName, newName string) (string, int)
+}
+
+// NewRenameFunc adapts r for ApplyToFiles, renaming oldName to newName and
+// passing on any warnings r reports for each file.
+func NewRenameFunc(r Renamer, oldName, newName string) RenameFunc {
+	return func(content string) (string, int, []Warning) {
+		out, n := r.Rename(content, oldName, newName)
+		if wr, ok := r.(interface{ Warnings() []Warning }); ok {
+			return out, n, wr.Warnings()
+		}
+		return out, n, nil
+	}
+}
+
+// reporter collects warnings about the file most recently renamed.  Renamers
+// that can decline to rename an occurrence embed it.
+type reporter struct {
+	warnings []Warning
+}
+
+// Warnings returns the warnings raised by the most recent Rename call.
+func (rp *reporter) Warnings() []Warning {
+	return rp.warnings
+}
+
+func (rp *reporter) reset() {
+	rp.warnings = nil
+}
+
+func (rp *reporter) warn(src string, pos int, format string, args ...any) {
+	rp.warnings = append(rp.warnings, Warning{
+		Line:    strings.Count(src[:pos], "\n") + 1,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
 // ClassRenamer handles renaming of classes, interfaces, and objects.
 //
 // Strategy: single-pass scan using a master regex that captures every possible
@@ -26,13 +69,80 @@ import (
 //   - companion / static access     OldName.bar
 //   - method reference              OldName::bar
 //
+// When FQN is set, each file is first checked for whether the simple name
+// actually refers to that class — through its package, explicit imports,
+// aliases and wildcard imports — so one of several same-named classes can be
+// renamed safely.  Qualified references are renamed only when their
+// qualifier is the class's package.
+//
+// With TestNames, the class name is also rewritten as a word inside
+// backtick-quoted function names, such as the test
+// fun `creates a User when email is valid`(), at their declarations and
+// calls.  Otherwise words inside quoted names are never touched.
+//
 // Non-goals (not renamed):
 //   - Local variable names that shadow the class name (requires scope analysis)
-//   - Contents of string literals or comments (we preserve those)
-type ClassRenamer struct{}
+//   - Contents of string literals or comments (we preserve those unless
+//     MatchOptions says otherwise)
+type ClassRenamer struct {
+	MatchOptions
+	FQN       string // optional: fully-qualified name of the class, e.g. com.example.User
+	TestNames bool   // also rename the word inside backtick-quoted function names
+}
 
 func (r *ClassRenamer) Rename(content, oldName, newName string) (string, int) {
-	return singlePassRename(content, oldName, newName, isClassContext)
+	if r.FQN == "" {
+		out, n := singlePassRename(content, oldName, newName, r.MatchOptions, isClassContext)
+		return r.renameTestNames(out, n, oldName, newName)
+	}
+
+	pkg, _ := splitFQN(r.FQN)
+	visible := resolvesToClass(content, parseImports(content), r.FQN)
+
+	out, n := singlePassRename(content, oldName, newName, r.MatchOptions, func(src string, start, end int) bool {
+		if !isClassContext(src, start, end) {
+			return false
+		}
+		// com.example.User, import com.example.User, import com.example.User as U
+		if qualifier, ok := qualifierBefore(src, start); ok {
+			return qualifier == pkg
+		}
+		return visible
+	})
+	if !visible {
+		return out, n
+	}
+	return r.renameTestNames(out, n, oldName, newName)
+}
+
+// renameTestNames rewrites oldName as a whole word inside the quoted
+// function names of src when r.TestNames is set: `creates a User`() becomes
+// `creates a UserAccount`().  n counts the renames made so far.
+func (r *ClassRenamer) renameTestNames(src string, n int, oldName, newName string) (string, int) {
+	if !r.TestNames {
+		return src, n
+	}
+	name := unquoteIdent(oldName)
+	code := codeTokens(tokenize(src))
+	var starts []int
+	for i, t := range code {
+		if t.kind != tokIdent || t.text[0] != '`' || t.text == "`"+name+"`" || i+1 == len(code) || code[i+1].text != "(" {
+			continue
+		}
+		for from := t.start + 1; ; {
+			k := strings.Index(src[from:t.end-1], name)
+			if k < 0 {
+				break
+			}
+			start := from + k
+			from = start + len(name)
+			if wordAt(src, start, from) {
+				starts = append(starts, start)
+			}
+		}
+	}
+	out, k := replaceAt(src, starts, name, unquoteIdent(newName))
+	return out, n + k
 }
 
 // isClassContext returns true when the character at position [start,end) within
@@ -74,26 +184,220 @@ func isClassContext(src string, start, end int) bool {
 //   - call site:          .oldName(  or  oldName(  (at statement start)
 //   - method reference:   ::oldName
 //   - named argument:     oldName =   — NOT renamed (it's a parameter label)
+//
+// When ClassName is set, only the method declared in that class is renamed.
+// Each call's receiver type is inferred (this, super, implicit receivers
+// inside the class body, typed or constructor-initialised variables,
+// Type.member); calls whose receiver cannot be resolved are reported via
+// Warnings instead of being renamed.  Given an Index, the rename covers the
+// method's whole override family: the declaration it overrides, every
+// override of it, and calls on any class in the hierarchy.
+//
+// When Signature is set, only the overload declaring exactly those parameter
+// types is renamed.  Each call is matched against the overloads in scope by
+// argument count, named-argument labels and the obvious types of literal
+// and constructor arguments; calls that fit several overloads are reported.
 type MethodRenamer struct {
-	ClassName string // optional: limit to calls on a specific class/receiver
+	MatchOptions
+	ClassName string    // optional: limit to calls on a specific class/receiver
+	Signature Signature // optional: limit to one overload; nil means all
+	Index     *Index    // optional: extends ClassName to its override family
+	reporter
 }
 
 func (r *MethodRenamer) Rename(content, oldName, newName string) (string, int) {
-	return singlePassRename(content, oldName, newName, r.isMethodContext)
+	r.reset()
+	if r.ClassName == "" && r.Signature == nil {
+		return singlePassRename(content, oldName, newName, r.MatchOptions, r.isMethodContext)
+	}
+
+	fd := parseFile(content)
+	var family map[string]bool
+	if r.ClassName != "" {
+		family = r.Index.memberClasses(r.ClassName, oldName, false)
+	}
+	overloads := r.overloads(fd, family, oldName)
+	return singlePassRename(content, oldName, newName, r.MatchOptions, func(src string, start, end int) bool {
+		return r.isMethodContext(src, start, end) &&
+			(family == nil || r.belongsToClass(fd, family, start, oldName)) &&
+			(r.Signature == nil || r.isOverload(fd, overloads, start, oldName))
+	})
+}
+
+// overloads returns the functions called name that a call may resolve to:
+// those declared in family, or anywhere when family is nil.  Declarations
+// come from the Index when there is one, otherwise from fd.
+func (r *MethodRenamer) overloads(fd *fileDecls, family map[string]bool, name string) []*funcDecl {
+	decls := fd.funcs
+	if r.Index != nil {
+		decls = r.Index.funcs[name]
+	}
+	var out []*funcDecl
+	for _, f := range decls {
+		if f.name == name && (family == nil || (f.owner != nil && family[f.owner.name])) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// isOverload decides whether the method occurrence at byte offset pos refers
+// to the overload r.Signature selects.
+func (r *MethodRenamer) isOverload(fd *fileDecls, overloads []*funcDecl, pos int, name string) bool {
+	i := fd.codeIndex(pos)
+	if i < 0 || fd.code[i].kind != tokIdent {
+		return true
+	}
+	if f := fd.funcAt(i); f != nil {
+		return r.Signature.matches(f)
+	}
+
+	cands := overloads
+	if args, ok := fd.callArgs(i + 1); ok {
+		cands = nil
+		for _, f := range overloads {
+			if f.accepts(args) {
+				cands = append(cands, f)
+			}
+		}
+	}
+
+	sigs := make(map[string]bool)
+	n := 0
+	for _, f := range cands {
+		if r.Signature.matches(f) {
+			n++
+		}
+		sigs[name+signatureOf(f).String()] = true
+	}
+	switch {
+	case n == 0:
+		return false
+	case n == len(cands):
+		return true
+	}
+	r.warn(fd.src, pos, "ambiguous call to %s — could be %s — left unchanged", name, strings.Join(sortedKeys(sigs), " or "))
+	return false
+}
+
+// belongsToClass decides whether the method occurrence at byte offset pos
+// refers to r.ClassName's member.  family holds the classes sharing it.
+// Occurrences outside code (comments and strings opted in via MatchOptions)
+// are accepted as they are.
+func (r *MethodRenamer) belongsToClass(fd *fileDecls, family map[string]bool, pos int, name string) bool {
+	i := fd.codeIndex(pos)
+	if i < 0 || fd.code[i].kind != tokIdent {
+		return true
+	}
+
+	// declaration: fun name(
+	if f := fd.funcAt(i); f != nil {
+		return f.receiver == "" && f.owner != nil && family[f.owner.name]
+	}
+
+	access, recv := fd.receiverEnd(i)
+	switch access {
+	case accessDot, accessReference:
+		if supers, ok := fd.superTypes(recv); ok {
+			return anyIn(supers, family)
+		}
+		typ, ok := fd.typeOfReceiver(recv)
+		if !ok {
+			r.warn(fd.src, pos, "cannot infer the receiver type of %s — left unchanged", name)
+			return false
+		}
+		return family[typ]
+	}
+
+	// Bare call or ::name — resolved against the receivers of enclosing
+	// extension functions, then the enclosing classes, innermost first.  The
+	// first that declares the method owns the call.
+	for _, recv := range fd.extensionReceivers(i) {
+		if family[recv] {
+			return true
+		}
+		if classDeclares(r.Index, fd, recv, name, false) {
+			return false
+		}
+	}
+	sawTarget := false
+	for c := fd.classAt(i); c != nil; c = c.outer {
+		if declaresFunc(c, name) {
+			return family[c.name]
+		}
+		if family[c.name] {
+			sawTarget = true
+		}
+	}
+	if sawTarget {
+		return true
+	}
+	for _, f := range fd.funcs {
+		if f.name == name && f.owner == nil && f.receiver == "" {
+			return false // a top-level or local function of the same name
+		}
+	}
+	r.warn(fd.src, pos, "cannot tell whether %s() refers to %s.%s — left unchanged", name, r.ClassName, name)
+	return false
+}
+
+// anyIn reports whether any of names is in set.
+func anyIn(names []string, set map[string]bool) bool {
+	for _, n := range names {
+		if set[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// classDeclares reports whether a class called cls declares the member
+// function or property name: any class of the project when ix is set,
+// otherwise a class of fd.
+func classDeclares(ix *Index, fd *fileDecls, cls, name string, property bool) bool {
+	if ix != nil {
+		declared, _ := ix.declaresMember(cls, name, property)
+		return declared
+	}
+	for _, c := range fd.classes {
+		if c.name != cls {
+			continue
+		}
+		if !property && declaresFunc(c, name) {
+			return true
+		}
+		for _, p := range c.props {
+			if property && p.name == name && p.receiver == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// declaresFunc reports whether c declares a member function called name.
+func declaresFunc(c *classDecl, name string) bool {
+	for _, f := range c.funcs {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *MethodRenamer) isMethodContext(src string, start, end int) bool {
 	pre := src[:start]
-	post := strings.TrimLeft(src[end:], " \t")
 
 	// Must have word boundaries
 	if len(pre) > 0 && isIdentChar(pre[len(pre)-1]) {
 		return false
 	}
-	if len(post) > 0 && isIdentChar(post[0]) {
+	if end < len(src) && isIdentChar(src[end]) {
 		return false
 	}
 
+	post := strings.TrimLeft(src[end:], " \t")
+
 	trimmedPre := strings.TrimRight(pre, " \t")
 
 	// Preceded by :: — this is a method reference: ::oldName or obj::oldName
@@ -121,26 +425,122 @@ func (r *MethodRenamer) isMethodContext(src string, start, end int) bool {
 //   - this access:   this.oldName
 //   - member access: receiver.oldName
 //   - named argument: oldName = value  (in constructor/function calls)
+//
+// When ClassName is set, the rename is driven by that class's declaration:
+// only member accesses on receivers of the class (or, given an Index, its
+// override family and subclasses), implicit-receiver reads inside those
+// classes that are not shadowed by a local or parameter, super.name, and
+// named arguments to the class's constructor are renamed.  Unresolvable
+// receivers are reported.
 type PropertyRenamer struct {
+	MatchOptions
 	ClassName string
+	Index     *Index // optional: lets subclasses in other files inherit the rename
+	reporter
 }
 
 func (r *PropertyRenamer) Rename(content, oldName, newName string) (string, int) {
-	return singlePassRename(content, oldName, newName, r.isPropertyContext)
+	r.reset()
+	if r.ClassName == "" {
+		return singlePassRename(content, oldName, newName, r.MatchOptions, r.isPropertyContext)
+	}
+
+	return replaceAt(content, r.matches(parseFile(content), oldName), oldName, newName)
+}
+
+// matches returns the offsets of the occurrences of oldName in fd that refer
+// to r.ClassName's property.
+func (r *PropertyRenamer) matches(fd *fileDecls, oldName string) []int {
+	family := r.Index.memberClasses(r.ClassName, oldName, true)
+	return findMatches(fd.src, fd.toks, oldName, r.MatchOptions, 0, len(fd.src), func(src string, start, end int) bool {
+		return r.isPropertyContext(src, start, end) && r.belongsToClass(fd, family, start, oldName)
+	})
+}
+
+// belongsToClass decides whether the property occurrence at byte offset pos
+// refers to the property declared in r.ClassName.  family holds the class
+// and its subclasses.
+func (r *PropertyRenamer) belongsToClass(fd *fileDecls, family map[string]bool, pos int, name string) bool {
+	i := fd.codeIndex(pos)
+	if i < 0 || fd.code[i].kind != tokIdent {
+		return true
+	}
+
+	// declaration: val name / class C(val name: T)
+	for _, p := range fd.props {
+		if p.nameTok == i {
+			return !p.local && p.receiver == "" && p.owner != nil && family[p.owner.name]
+		}
+	}
+
+	access, recv := fd.receiverEnd(i)
+	switch access {
+	case accessDot, accessReference:
+		if supers, ok := fd.superTypes(recv); ok {
+			return anyIn(supers, family)
+		}
+		typ, ok := fd.typeOfReceiver(recv)
+		if !ok {
+			r.warn(fd.src, pos, "cannot infer the receiver type of %s — left unchanged", name)
+			return false
+		}
+		return family[typ]
+	case accessBareRef:
+		return false
+	}
+
+	// class header: class C(name: T, other: T = name) : Base(name)
+	if c := fd.headerAt(i); c != nil {
+		if prm := c.primary.param(name); prm != nil {
+			return prm.nameTok != i && prm.binding != "" && family[c.name]
+		}
+	}
+
+	// named argument: ClassName(name = ...)
+	if callee, ok := fd.namedArgCallee(i); ok {
+		return fd.code[callee].kind == tokIdent && unquoteIdent(fd.code[callee].text) == r.ClassName &&
+			(callee == 0 || fd.code[callee-1].text != ".")
+	}
+
+	// implicit receiver: a bare read inside the class or a subclass, unless a
+	// local, parameter, enclosing extension's receiver or other class's member
+	// of the same name is closer
+	b := fd.lookup(name, i)
+	if b == nil || b.member {
+		for _, recv := range fd.extensionReceivers(i) {
+			if family[recv] {
+				return true
+			}
+			if classDeclares(r.Index, fd, recv, name, true) {
+				return false
+			}
+		}
+	}
+	if b != nil {
+		return b.prop != nil && !b.prop.local && b.prop.owner != nil && family[b.prop.owner.name]
+	}
+	for c := fd.classAt(i); c != nil; c = c.outer {
+		if family[c.name] {
+			return true
+		}
+	}
+	r.warn(fd.src, pos, "cannot tell whether %s refers to %s.%s — left unchanged", name, r.ClassName, name)
+	return false
 }
 
 func (r *PropertyRenamer) isPropertyContext(src string, start, end int) bool {
 	pre := src[:start]
-	post := strings.TrimLeft(src[end:], " \t")
 
 	// Word boundaries
 	if len(pre) > 0 && isIdentChar(pre[len(pre)-1]) {
 		return false
 	}
-	if len(post) > 0 && isIdentChar(post[0]) {
+	if end < len(src) && isIdentChar(src[end]) {
 		return false
 	}
 
+	post := strings.TrimLeft(src[end:], " \t")
+
 	trimmedPre := strings.TrimRight(pre, " \t")
 
 	// Preceded by . (member access) — accept
@@ -172,76 +572,517 @@ func (r *PropertyRenamer) isPropertyContext(src string, start, end int) bool {
 	return false
 }
 
-// ParameterRenamer renames parameters within function signatures and their bodies.
-// This is the most conservative renamer — it only operates within a single file
-// and only within function scopes.
-type ParameterRenamer struct{}
+// ParameterRenamer renames a function or constructor parameter: its
+// declaration, its uses in the signature and body, and named arguments
+// (name = value) at the call sites.
+//
+// Every function and constructor declaring a parameter called oldName is a
+// target unless Function or ClassName narrow the set.  A constructor counts
+// as a function named after its class; with ClassName, overrides of the
+// class's functions are targets too, so the hierarchy keeps consistent
+// parameter names.
+//
+// Constructor parameters are also renamed in property initializers, init
+// blocks and the supertype list, at this(...) / super(...) delegation, and
+// — for data classes — in copy(name = ...) calls whose receiver is known.
+// A val/var parameter is a property as well, so its member accesses are
+// renamed the way PropertyRenamer does.  Given an Index, call sites in every
+// file are matched against the functions declared anywhere in the project —
+// by name, then by receiver type and argument count when several same-named
+// functions declare the parameter.  Call sites that still can't be matched
+// are reported via Warnings instead of being renamed.
+type ParameterRenamer struct {
+	MatchOptions
+	Function  string // optional: only parameters of functions with this name
+	ClassName string // optional: only parameters of this class's member functions
+	Index     *Index // optional: resolves call sites against the whole project
+	reporter
+
+	families map[string]map[string]bool // memberClasses by function name, per Rename
+}
 
 func (r *ParameterRenamer) Rename(content, oldName, newName string) (string, int) {
-	// We process the file function-by-function
-	return renameParameters(content, oldName, newName)
+	r.reset()
+	r.families = make(map[string]map[string]bool)
+
+	fd := parseFile(content)
+	starts := parameterMatches(fd, oldName, r.MatchOptions, func(open int) bool {
+		for _, f := range fd.funcs {
+			if f.paramsOpen == open {
+				return r.isTarget(f, oldName)
+			}
+		}
+		return r.Function == "" && r.ClassName == "" // anonymous function
+	})
+	for _, c := range fd.classes {
+		for _, ctor := range c.constructors() {
+			if r.isTarget(ctor, oldName) {
+				starts = append(starts, r.constructorMatches(fd, ctor, oldName)...)
+			}
+		}
+	}
+	for _, cls := range r.promotedOwners(fd, oldName) {
+		pr := &PropertyRenamer{MatchOptions: r.MatchOptions, ClassName: cls, Index: r.Index}
+		starts = append(starts, pr.matches(fd, oldName)...)
+		r.warnings = append(r.warnings, pr.warnings...)
+	}
+	starts = append(starts, r.namedArguments(fd, oldName)...)
+	return replaceAt(content, sortedUnique(starts), oldName, newName)
+}
+
+// isTarget reports whether the parameter oldName of f is being renamed.
+func (r *ParameterRenamer) isTarget(f *funcDecl, oldName string) bool {
+	if f.copyOf != nil {
+		f = f.copyOf
+	}
+	if f.param(oldName) == nil || (r.Function != "" && f.name != r.Function) {
+		return false
+	}
+	if r.ClassName == "" {
+		return true
+	}
+	if f.owner == nil {
+		return false
+	}
+	if f.ctor {
+		return f.owner.name == r.ClassName
+	}
+	family, ok := r.families[f.name]
+	if !ok {
+		family = r.Index.memberClasses(r.ClassName, f.name, false)
+		r.families[f.name] = family
+	}
+	return family[f.owner.name]
+}
+
+// namedArguments returns the offsets of oldName used as a named-argument
+// label in calls to a target function.
+func (r *ParameterRenamer) namedArguments(fd *fileDecls, oldName string) []int {
+	var starts []int
+	for i, t := range fd.code {
+		if t.kind != tokIdent || t.text != oldName {
+			continue
+		}
+		callee, ok := fd.namedArgCallee(i)
+		if !ok || fd.code[callee].kind != tokIdent {
+			continue
+		}
+		if r.callsTarget(fd, callee, oldName) {
+			starts = append(starts, t.start)
+		}
+	}
+	return starts
+}
+
+// constructorMatches returns the occurrences of oldName that refer to the
+// parameter of ctor: its declaration, and uses in the constructor's body,
+// delegation call and — for a primary constructor — the class header and
+// body.
+func (r *ParameterRenamer) constructorMatches(fd *fileDecls, ctor *funcDecl, oldName string) []int {
+	prm := ctor.param(oldName)
+	cls := ctor.owner
+
+	var starts []int
+	end := ctor.paramsEnd
+	if ctor == cls.primary {
+		end = cls.headerEnd
+		if cls.bodyClose >= 0 {
+			end = cls.bodyClose
+		}
+	} else if s := fd.scopeAt(ctor.paramsOpen + 1); s.fn == ctor {
+		end = s.close - 1
+	}
+
+	return append(starts, findMatches(fd.src, fd.toks, oldName, r.MatchOptions, fd.code[ctor.paramsOpen].start, fd.code[end].end,
+		func(src string, start, stop int) bool {
+			i := fd.codeIndex(start)
+			switch {
+			case i < 0:
+				return true
+			case i == prm.nameTok:
+				return true
+			case !isParameterContext(src, start, stop):
+				return false
+			}
+			if access, _ := fd.receiverEnd(i); access != accessBare {
+				return false
+			}
+			if _, label := fd.namedArgCallee(i); label {
+				return false
+			}
+			if fd.headerAt(i) == cls {
+				return true // default values and supertype arguments
+			}
+			b := fd.lookup(oldName, i)
+			return b != nil && b.param == prm
+		})...)
+}
+
+// promotedOwners returns the names of the classes whose target primary
+// constructor declares oldName as a val/var parameter, sorted.  Their
+// property accesses are renamed along with the parameter.
+func (r *ParameterRenamer) promotedOwners(fd *fileDecls, oldName string) []string {
+	classes := fd.classes
+	if r.Index != nil {
+		classes = nil
+		for _, cs := range r.Index.classes {
+			classes = append(classes, cs...)
+		}
+	}
+	owners := make(map[string]bool)
+	for _, c := range classes {
+		if c.primary == nil || !r.isTarget(c.primary, oldName) {
+			continue
+		}
+		if prm := c.primary.param(oldName); prm.binding != "" {
+			owners[c.name] = true
+		}
+	}
+	return sortedKeys(owners)
+}
+
+// callees returns the functions and constructors that a call whose callee
+// name is code[callee] may invoke and that declare a parameter oldName.
+func (r *ParameterRenamer) callees(fd *fileDecls, callee int, oldName string) []*funcDecl {
+	name := unquoteIdent(fd.code[callee].text)
+	classes := func(name string) []*classDecl {
+		if r.Index != nil {
+			return r.Index.classes[name]
+		}
+		var out []*classDecl
+		for _, c := range fd.classes {
+			if c.name == name {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+
+	var decls []*funcDecl
+	switch {
+	case name == "this" || name == "super":
+		// constructor delegation: constructor(...) : this(...)
+		c := fd.classAt(callee)
+		if c == nil {
+			return nil
+		}
+		if name == "this" {
+			decls = c.constructors()
+			break
+		}
+		for _, st := range c.supertypes {
+			for _, sc := range classes(st) {
+				decls = append(decls, sc.constructors()...)
+			}
+		}
+	default:
+		decls = fd.funcs
+		if r.Index != nil {
+			decls = r.Index.funcs[name]
+		}
+		for _, c := range classes(name) {
+			decls = append(decls, c.constructors()...)
+		}
+		if name == "copy" {
+			for _, c := range r.dataClasses(fd) {
+				decls = append(decls, &funcDecl{name: "copy", params: c.primary.params, owner: c, copyOf: c.primary})
+			}
+		}
+	}
+
+	var cands []*funcDecl
+	for _, f := range decls {
+		if (f.name == name || f.ctor) && f.param(oldName) != nil {
+			cands = append(cands, f)
+		}
+	}
+	return cands
+}
+
+// dataClasses returns the data classes with a primary constructor declared
+// in the project, or in fd without an Index.
+func (r *ParameterRenamer) dataClasses(fd *fileDecls) []*classDecl {
+	var all []*classDecl
+	if r.Index == nil {
+		all = fd.classes
+	} else {
+		for _, cs := range r.Index.classes {
+			all = append(all, cs...)
+		}
+	}
+	var out []*classDecl
+	for _, c := range all {
+		if c.primary != nil && hasString(c.modifiers, "data") {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// callsTarget decides whether the call whose callee name is code[callee]
+// resolves to a target function.  Only functions declaring oldName can be
+// called with it as a named argument, so the name alone usually settles it.
+func (r *ParameterRenamer) callsTarget(fd *fileDecls, callee int, oldName string) bool {
+	name := unquoteIdent(fd.code[callee].text)
+	cands := r.callees(fd, callee, oldName)
+
+	targets := func(fs []*funcDecl) int {
+		n := 0
+		for _, f := range fs {
+			if r.isTarget(f, oldName) {
+				n++
+			}
+		}
+		return n
+	}
+	switch n := targets(cands); {
+	case n == 0:
+		return false
+	case name == "copy":
+		// every data class has a copy(), so the receiver must say which
+		access, recv := fd.receiverEnd(callee)
+		known := access == accessBare && fd.classAt(callee) != nil
+		if access == accessDot {
+			_, known = fd.typeOfReceiver(recv)
+		}
+		if !known {
+			r.warn(fd.src, fd.code[callee].start, "cannot infer the receiver type of copy(%s = ...) — left unchanged", oldName)
+			return false
+		}
+	case n == len(cands):
+		return true
+	}
+
+	cands = r.narrowCallees(fd, callee, cands)
+	switch n := targets(cands); {
+	case n == 0 && len(cands) > 0:
+		return false
+	case n > 0 && n == len(cands):
+		return true
+	}
+	r.warn(fd.src, fd.code[callee].start, "cannot tell which %s() the named argument %s belongs to — left unchanged", name, oldName)
+	return false
+}
+
+// narrowCallees keeps the functions in cands that the call whose callee name
+// is code[callee] may resolve to, judging by its receiver and its arguments.
+func (r *ParameterRenamer) narrowCallees(fd *fileDecls, callee int, cands []*funcDecl) []*funcDecl {
+	keep := func(ok func(f *funcDecl) bool) {
+		var out []*funcDecl
+		for _, f := range cands {
+			if ok(f) {
+				out = append(out, f)
+			}
+		}
+		cands = out
+	}
+
+	// constructors are selected by name alone
+	member := func(f *funcDecl) bool { return f.owner != nil && !f.ctor }
+	switch access, recv := fd.receiverEnd(callee); access {
+	case accessDot:
+		if supers, ok := fd.superTypes(recv); ok {
+			keep(func(f *funcDecl) bool { return f.ctor || (member(f) && anyIn(supers, r.Index.family(f.owner.name))) })
+		} else if typ, ok := fd.typeOfReceiver(recv); ok {
+			keep(func(f *funcDecl) bool {
+				return f.ctor || f.receiver == typ || (member(f) && r.Index.family(f.owner.name)[typ])
+			})
+		}
+	case accessBare:
+		// the innermost enclosing class inheriting a candidate wins, then
+		// top-level functions
+		found := false
+		for c := fd.classAt(callee); c != nil && !found; c = c.outer {
+			for _, f := range cands {
+				if member(f) && r.Index.family(f.owner.name)[c.name] {
+					found = true
+				}
+			}
+			if found {
+				keep(func(f *funcDecl) bool { return f.ctor || (member(f) && r.Index.family(f.owner.name)[c.name]) })
+			}
+		}
+		if !found {
+			keep(func(f *funcDecl) bool { return f.ctor || f.owner == nil })
+		}
+	}
+
+	if args, ok := fd.callArgs(callee + 1); ok {
+		keep(func(f *funcDecl) bool { return f.accepts(args) })
+	}
+	return cands
 }
 
 // ─── core engine ──────────────────────────────────────────────────────────────
 
 // singlePassRename scans src for all word-boundary occurrences of oldName and
-// replaces those for which contextFn returns true.  Returns the modified source
-// and replacement count.
-func singlePassRename(src, oldName, newName string, contextFn func(src string, start, end int) bool) (string, int) {
-	pat := regexp.MustCompile(`\b` + regexp.QuoteMeta(oldName) + `\b`)
+// replaces those for which contextFn returns true.  Occurrences inside
+// comments and literal string text are skipped unless opts includes them;
+// code inside string templates is always considered.  Returns the modified
+// source and replacement count.
+func singlePassRename(src, oldName, newName string, opts MatchOptions, contextFn func(src string, start, end int) bool) (string, int) {
+	toks := tokenize(src)
+	return replaceAt(src, findMatches(src, toks, oldName, opts, 0, len(src), contextFn), oldName, newName)
+}
 
-	count := 0
-	var buf strings.Builder
-	last := 0
+// findMatches returns the start offsets of word-boundary occurrences of
+// oldName within src[lo:hi] that opts permits and contextFn accepts.
+//
+// oldName may be quoted in backticks.  A backtick-quoted identifier is one
+// token: it matches only when it quotes exactly oldName, as `User` does
+// User, and is then passed to contextFn backticks included, while the
+// offset returned is that of the name inside.  Words inside longer quoted
+// names such as `creates a User` never match.  Names that can't be written
+// without backticks, such as `in`, match only quoted.
+func findMatches(src string, toks []token, oldName string, opts MatchOptions, lo, hi int, contextFn func(src string, start, end int) bool) []int {
+	name := unquoteIdent(oldName)
+	bare := isIdentifier(name) && !hardKeywords[name]
+	var starts []int
+	for from := lo; from < hi; {
+		k := strings.Index(src[from:hi], name)
+		if k < 0 || name == "" {
+			break
+		}
+		start, end := from+k, from+k+len(name)
+		cstart, cend := start, end
+		if i := tokenAt(toks, start); i >= 0 && toks[i].kind == tokIdent && toks[i].text[0] == '`' {
+			from = toks[i].end
+			if toks[i].start+1 != start || toks[i].end-1 != end {
+				continue
+			}
+			cstart, cend = toks[i].start, toks[i].end
+		} else {
+			if !bare || !wordAt(src, start, end) {
+				from = start + 1
+				continue
+			}
+			from = end
+		}
+		if !opts.allows(kindAt(toks, start)) {
+			continue
+		}
+		view := src
+		if i := tokenAt(toks, start); i >= 0 && isShortTemplate(toks, i) {
+			// Hide the literal text after "$name" from contextFn.
+			view = src[:cend]
+		}
+		if contextFn(view, cstart, cend) {
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
+
+// sortedUnique sorts starts and drops duplicate offsets.
+func sortedUnique(starts []int) []int {
+	sort.Ints(starts)
+	out := starts[:0]
+	for i, s := range starts {
+		if i == 0 || s != starts[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
 
-	for _, loc := range pat.FindAllStringIndex(src, -1) {
-		start, end := loc[0], loc[1]
+// replaceAt replaces the occurrences of oldName beginning at each offset in
+// starts (ascending, non-overlapping) with newName.  An occurrence already
+// quoted in backticks — a quoted identifier, or `User` in a KDoc comment —
+// gets newName without its own backticks, and a quoted identifier loses
+// its backticks when newName needs none.
+func replaceAt(src string, starts []int, oldName, newName string) (string, int) {
+	if len(starts) == 0 {
+		return src, 0
+	}
 
-		if contextFn(src, start, end) {
+	oldLen := len(unquoteIdent(oldName))
+	bare := unquoteIdent(newName)
+	plain := isIdentifier(bare) && !hardKeywords[bare]
+	var toks []token // tokenized on the first quoted occurrence
+	isIdentToken := func(pos int) bool {
+		if toks == nil {
+			toks = tokenize(src)
+		}
+		k := tokenAt(toks, pos)
+		return k >= 0 && toks[k].kind == tokIdent
+	}
+	var buf strings.Builder
+	last := 0
+	for _, start := range starts {
+		end := start + oldLen
+		quoted := start > 0 && src[start-1] == '`' && end < len(src) && src[end] == '`'
+		switch {
+		case quoted && plain && isIdentToken(start):
+			// `in` renamed to inside needs no backticks
+			buf.WriteString(src[last : start-1])
+			buf.WriteString(bare)
+			end++
+		case quoted:
+			buf.WriteString(src[last:start])
+			buf.WriteString(bare)
+		default:
 			buf.WriteString(src[last:start])
 			buf.WriteString(newName)
-			last = end
-			count++
 		}
+		last = end
 	}
 	buf.WriteString(src[last:])
 
-	return buf.String(), count
+	return buf.String(), len(starts)
 }
 
 // ─── parameter rename ─────────────────────────────────────────────────────────
 
-// renameParameters renames a parameter within all function scopes where it
-// appears in the parameter list.
-func renameParameters(src, oldName, newName string) (string, int) {
-	total := 0
-
-	// Find all function headers that declare oldName as a parameter.
-	// We scan for "fun " + optional name + "(...oldName...)" blocks.
-	// Then we rename inside the matched brace scope.
+// parameterMatches returns the occurrences of oldName within the signature
+// and body of every function that declares it as a parameter and whose
+// parameter list, opening at code index open, include accepts.  Named
+// argument labels are left to the caller: they belong to the function being
+// called, not to the enclosing one.
+func parameterMatches(fd *fileDecls, oldName string, opts MatchOptions, include func(open int) bool) []int {
+	src, toks, code := fd.src, fd.toks, fd.code
+	isParamUse := func(src string, start, end int) bool {
+		if !isParameterContext(src, start, end) {
+			return false
+		}
+		if i := fd.codeIndex(start); i >= 0 {
+			_, label := fd.namedArgCallee(i)
+			return !label
+		}
+		return true
+	}
 
-	funPat := regexp.MustCompile(`(?m)\bfun\b`)
-	result := src
-	offset := 0
+	// Collect occurrences per function scope first and apply them in one go,
+	// so nested functions declaring the same parameter are not counted twice.
+	seen := make(map[int]bool)
+	var starts []int
 
-	for _, loc := range funPat.FindAllStringIndex(src, -1) {
-		// loc is relative to original src; adjust to result using offset
-		funStart := loc[0] + offset
+	for i, t := range code {
+		if t.kind != tokIdent || t.text != "fun" {
+			continue
+		}
 
 		// find the opening paren of parameter list
-		parenOpen := strings.Index(result[funStart:], "(")
-		if parenOpen < 0 {
+		open := -1
+		for j := i + 1; j < len(code); j++ {
+			if code[j].text == "(" {
+				open = j
+				break
+			}
+			if code[j].text == "{" || code[j].text == "}" || code[j].text == "=" {
+				break
+			}
+		}
+		if open < 0 || !include(open) {
 			continue
 		}
-		parenOpen += funStart
 
 		// find matching closing paren
-		parenClose := findMatchingParen(result, parenOpen)
-		if parenClose < 0 {
+		close := matchingToken(code, open)
+		if close < 0 {
 			continue
 		}
 
-		paramSection := result[parenOpen : parenClose+1]
+		paramSection := src[code[open].start:code[close].end]
 
 		// Does this function have oldName as a parameter?
 		if !hasParamName(paramSection, oldName) {
@@ -249,24 +1090,22 @@ func renameParameters(src, oldName, newName string) (string, int) {
 		}
 
 		// Find function body: either expression body (= ...) or block body ({ ... })
-		bodyStart, bodyEnd := findFunctionBody(result, parenClose+1)
-		if bodyStart < 0 {
-			// no body found; still rename in signature
-			bodyStart = parenOpen
-			bodyEnd = parenClose + 1
+		scopeEnd := code[close].end
+		if _, bodyEnd := functionBody(src, code, close+1); bodyEnd >= 0 {
+			scopeEnd = code[bodyEnd].end
 		}
 
 		// Rename in signature + body
-		scopeContent := result[parenOpen:bodyEnd]
-		renamed, n := singlePassRename(scopeContent, oldName, newName, isParameterContext)
-		if n > 0 {
-			result = result[:parenOpen] + renamed + result[bodyEnd:]
-			offset += len(renamed) - len(scopeContent)
-			total += n
+		for _, s := range findMatches(src, toks, oldName, opts, code[open].start, scopeEnd, isParamUse) {
+			if !seen[s] {
+				seen[s] = true
+				starts = append(starts, s)
+			}
 		}
 	}
 
-	return result, total
+	sort.Ints(starts)
+	return starts
 }
 
 // hasParamName checks whether a parameter list string contains oldName as a
@@ -302,13 +1141,28 @@ func isParameterContext(src string, start, end int) bool {
 
 // ─── brace/paren matching helpers ─────────────────────────────────────────────
 
-func findMatchingParen(src string, open int) int {
+// matchingToken returns the index of the bracket closing code[open], which
+// must be one of ( [ {.  code must not contain comments; brackets inside
+// string literals are never seen because literals are single tokens.
+func matchingToken(code []token, open int) int {
+	var openCh, closeCh string
+	switch code[open].text {
+	case "(":
+		openCh, closeCh = "(", ")"
+	case "[":
+		openCh, closeCh = "[", "]"
+	case "{":
+		openCh, closeCh = "{", "}"
+	default:
+		return -1
+	}
+
 	depth := 0
-	for i := open; i < len(src); i++ {
-		switch src[i] {
-		case '(':
+	for i := open; i < len(code); i++ {
+		switch code[i].text {
+		case openCh:
 			depth++
-		case ')':
+		case closeCh:
 			depth--
 			if depth == 0 {
 				return i
@@ -318,71 +1172,113 @@ func findMatchingParen(src string, open int) int {
 	return -1
 }
 
-func findFunctionBody(src string, afterParams int) (int, int) {
-	// skip whitespace and return type annotation
-	i := afterParams
-	for i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r') {
-		i++
-	}
+// functionBody locates the body of a function whose parameter list closes
+// just before code[after].  It returns the indices of the first and last
+// body tokens: { and its matching } for a block body, or = and the last token
+// of the expression for an expression body.  (-1, -1) means no body.
+func functionBody(src string, code []token, after int) (int, int) {
+	i := after
 
-	// return type annotation: ": Type"
-	if i < len(src) && src[i] == ':' {
-		// skip to next { or =
-		for i < len(src) && src[i] != '{' && src[i] != '=' && src[i] != '\n' {
-			i++
+	// return type annotation and where-clauses: skip to the next { or = at
+	// bracket depth 0
+	depth := 0
+	for ; i < len(code); i++ {
+		t := code[i].text
+		if depth == 0 && (t == "{" || t == "=") {
+			break
+		}
+		switch t {
+		case "(", "[", "<":
+			depth++
+		case ")", "]", ">":
+			if depth > 0 {
+				depth--
+			}
+		case "}", ";":
+			return -1, -1
+		}
+		if depth == 0 && code[i].kind == tokIdent && isDeclKeyword(code[i].text) {
+			return -1, -1
 		}
 	}
 
-	if i >= len(src) {
+	if i >= len(code) {
 		return -1, -1
 	}
 
-	switch src[i] {
-	case '{':
-		end := findMatchingBrace(src, i)
+	if code[i].text == "{" {
+		end := matchingToken(code, i)
 		if end < 0 {
 			return -1, -1
 		}
-		return i, end + 1
-	case '=':
-		// expression body — goes to end of line
-		lineEnd := strings.Index(src[i:], "\n")
-		if lineEnd < 0 {
-			return i, len(src)
-		}
-		return i, i + lineEnd + 1
+		return i, end
 	}
-
-	return -1, -1
+	return i, expressionEnd(src, code, i+1)
 }
 
-func findMatchingBrace(src string, open int) int {
+// expressionEnd returns the index of the last token of the expression that
+// starts at code[from].  The expression ends at a newline outside brackets
+// unless the line obviously continues, or at a closing bracket that belongs
+// to an enclosing construct.
+func expressionEnd(src string, code []token, from int) int {
 	depth := 0
-	for i := open; i < len(src); i++ {
-		switch src[i] {
-		case '{':
+	last := from - 1
+	for i := from; i < len(code); i++ {
+		t := code[i].text
+		if i > from && depth == 0 && strings.Contains(src[code[i-1].end:code[i].start], "\n") &&
+			!continuesLine(code[i-1].text) && !startsContinuation(t) {
+			break
+		}
+		switch t {
+		case "(", "[", "{":
 			depth++
-		case '}':
+		case ")", "]", "}":
+			if depth == 0 {
+				return last
+			}
 			depth--
+		case ";", ",":
 			if depth == 0 {
-				return i
+				return last
 			}
 		}
+		last = i
 	}
-	return -1
+	return last
 }
 
-// ─── utilities ────────────────────────────────────────────────────────────────
+// continuesLine reports whether a token ending a line means the expression
+// carries on past the line break (a binary operator, an open bracket).
+func continuesLine(t string) bool {
+	switch t {
+	case ".", "?", ":", "=", "+", "-", "*", "/", "%", "&", "|", "<", ">", "(", "[", "{", ",":
+		return true
+	}
+	return t == "as" || t == "is" || t == "in" || t == "to" || t == "else"
+}
 
-func isIdentChar(c byte) bool {
-	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_'
+// startsContinuation reports whether a token starting a line continues the
+// expression on the previous line (.member, ?.member, ?: fallback, && / ||).
+func startsContinuation(t string) bool {
+	switch t {
+	case ".", "?", "&", "|":
+		return true
+	}
+	return t == "as" || t == "else"
 }
 
-// ValidateIdentifier checks that s is a valid Kotlin identifier.
-func ValidateIdentifier(s string) error {
-	valid := regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
-	if !valid.MatchString(s) {
-		return fmt.Errorf("invalid Kotlin identifier: %q", s)
+// isDeclKeyword reports whether s starts a new declaration, which means a
+// header being scanned has ended.
+func isDeclKeyword(s string) bool {
+	switch s {
+	case "fun", "val", "var", "class", "interface", "object", "typealias", "constructor", "init":
+		return true
 	}
-	return nil
+	return false
+}
+
+// ─── utilities ────────────────────────────────────────────────────────────────
+
+func isIdentChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_'
 }
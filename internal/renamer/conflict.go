@@ -0,0 +1,602 @@
+package renamer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Conflict is an existing declaration, import or use that a rename would
+// clash with.
+type Conflict struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+// FindConflicts checks the rename of oldName to newName that r would make in
+// the files paths against the declarations in ix, before anything is
+// written.  It reports:
+//   - redeclarations:   a class, typealias or top-level property newName in the
+//     same package; a property, entry, nested class or parameter newName in
+//     the same class, function or scope
+//   - overload clashes: a function newName with the same parameter types, or
+//     a member newName that would take precedence over a renamed extension
+//   - shadowing:        a local or parameter newName that would capture uses
+//     of the renamed symbol, or uses of another newName that a renamed local,
+//     parameter or type parameter would capture
+//   - import clashes:   a file using the renamed declaration that already
+//     imports a different newName
+func FindConflicts(r Renamer, ix *Index, paths []string, oldName, newName string) []Conflict {
+	oldName, newName = unquoteIdent(oldName), unquoteIdent(newName)
+	if ix == nil || oldName == newName {
+		return nil
+	}
+	c := &conflictFinder{ix: ix, old: oldName, new: newName, seen: make(map[Conflict]bool)}
+	for _, path := range paths {
+		if fd := ix.files[path]; fd != nil {
+			c.renamed = append(c.renamed, fd)
+		}
+	}
+	sort.Slice(c.renamed, func(i, j int) bool { return c.renamed[i].path < c.renamed[j].path })
+
+	switch r := r.(type) {
+	case *ClassRenamer:
+		c.classifiers(r.FQN, false)
+	case *TypeAliasRenamer:
+		c.classifiers(r.FQN, true)
+	case *TopLevelRenamer:
+		c.topLevel(r.FQN)
+	case *MethodRenamer:
+		c.methods(r)
+	case *PropertyRenamer:
+		c.properties(r.ClassName)
+	case *ExtensionRenamer:
+		c.extensions(r.Receiver)
+	case *EnumEntryRenamer:
+		c.enumEntries(r.ClassName)
+	case *ParameterRenamer:
+		c.parameters(r)
+	case *LocalRenamer:
+		c.locals(r)
+	case *TypeParameterRenamer:
+		c.typeParams(r)
+	}
+
+	sort.SliceStable(c.found, func(i, j int) bool {
+		if c.found[i].Path != c.found[j].Path {
+			return c.found[i].Path < c.found[j].Path
+		}
+		return c.found[i].Line < c.found[j].Line
+	})
+	return c.found
+}
+
+type conflictFinder struct {
+	ix       *Index
+	old, new string
+	renamed  []*fileDecls // the files being renamed, sorted
+	found    []Conflict
+	seen     map[Conflict]bool
+}
+
+func (c *conflictFinder) add(fd *fileDecls, pos int, format string, args ...any) {
+	cf := Conflict{Path: fd.path, Line: positionOf(fd, pos).Line, Message: fmt.Sprintf(format, args...)}
+	if !c.seen[cf] {
+		c.seen[cf] = true
+		c.found = append(c.found, cf)
+	}
+}
+
+// all returns every indexed file, sorted.
+func (c *conflictFinder) all() []*fileDecls {
+	var out []*fileDecls
+	for _, path := range sortedKeys(c.ix.pathSet()) {
+		out = append(out, c.ix.files[path])
+	}
+	return out
+}
+
+// ─── package level ────────────────────────────────────────────────────────────
+
+// classifiers checks a class or typealias rename: fqn, or with an empty fqn
+// every class called old.
+func (c *conflictFinder) classifiers(fqn string, alias bool) {
+	pkg, _ := splitFQN(fqn)
+	for _, fd := range c.all() {
+		if fqn != "" && fd.pkg != pkg {
+			continue
+		}
+		if alias {
+			for _, a := range fd.aliases {
+				if a.name == c.old {
+					c.packageClassifier(fd.pkg)
+				}
+			}
+			continue
+		}
+		for _, cls := range fd.classes {
+			switch {
+			case cls.name != c.old || fqn != "" && cls.outer != nil:
+			case cls.outer == nil:
+				c.packageClassifier(fd.pkg)
+			default:
+				for _, sib := range fd.classes {
+					if sib.outer == cls.outer && sib.name == c.new {
+						c.add(fd, fd.code[sib.nameTok].start, "%s already declares a nested %s %s", cls.outer.name, sib.kind, c.new)
+					}
+				}
+			}
+		}
+	}
+}
+
+// packageClassifier reports a class or typealias new already declared in
+// pkg, and files whose imports would clash with the renamed pkg.old.
+func (c *conflictFinder) packageClassifier(pkg string) {
+	for _, fd := range c.all() {
+		if fd.pkg != pkg {
+			continue
+		}
+		for _, cls := range fd.classes {
+			if cls.outer == nil && cls.name == c.new {
+				c.add(fd, fd.code[cls.nameTok].start, "%s %s already exists in %s", cls.kind, c.new, packageLabel(pkg))
+			}
+		}
+		for _, a := range fd.aliases {
+			if a.name == c.new {
+				c.add(fd, fd.code[a.nameTok].start, "typealias %s already exists in %s", c.new, packageLabel(pkg))
+			}
+		}
+	}
+	c.importClashes(pkg)
+}
+
+// topLevel checks a top-level function or property rename.
+func (c *conflictFinder) topLevel(fqn string) {
+	pkg, _ := splitFQN(fqn)
+	for _, fd := range c.all() {
+		if fd.pkg != pkg {
+			continue
+		}
+		for _, f := range fd.funcs {
+			if f.name == c.old && f.owner == nil && f.receiver == "" && fd.scopeAt(f.nameTok) == fd.root {
+				c.overloadClash(f, func(g *funcDecl, gfd *fileDecls) bool {
+					return gfd.pkg == pkg && g.owner == nil && g.receiver == "" && gfd.scopeAt(g.nameTok) == gfd.root
+				}, packageLabel(pkg))
+			}
+		}
+		for _, p := range fd.props {
+			if p.name != c.old || p.owner != nil || p.local || p.receiver != "" {
+				continue
+			}
+			for _, other := range c.all() {
+				for _, q := range other.props {
+					if other.pkg == pkg && q.name == c.new && q.owner == nil && !q.local && q.receiver == "" {
+						c.add(other, other.code[q.nameTok].start, "property %s already exists in %s", c.new, packageLabel(pkg))
+					}
+				}
+			}
+			c.importClashes(pkg)
+		}
+	}
+}
+
+// importClashes reports renamed files that use pkg.old — through an
+// explicit or wildcard import, or from the same package — and already bring
+// a different new into scope with an explicit import.
+func (c *conflictFinder) importClashes(pkg string) {
+	fqn := qualify(pkg, c.old)
+	for _, fd := range c.renamed {
+		uses := fd.pkg == pkg
+		for _, imp := range fd.imports {
+			if imp.wildcard && imp.path == pkg || !imp.wildcard && imp.path == fqn && imp.alias == "" {
+				uses = true
+			}
+		}
+		if !uses || !mentions(fd, c.old) {
+			continue
+		}
+		for _, imp := range fd.imports {
+			if !imp.wildcard && imp.name() == c.new && imp.path != qualify(pkg, c.new) {
+				c.add(fd, imp.start, "imports %s, which would clash with the renamed %s", imp.path, fqn)
+			}
+		}
+	}
+}
+
+// ─── members ──────────────────────────────────────────────────────────────────
+
+// methods checks a method rename: overload clashes in each declaring class
+// (or scope, for local and top-level functions), and unqualified calls that
+// a local or parameter called new would capture.
+func (c *conflictFinder) methods(r *MethodRenamer) {
+	var family map[string]bool
+	if r.ClassName != "" {
+		family = c.ix.memberClasses(r.ClassName, c.old, false)
+	}
+	for _, fd := range c.all() {
+		for _, f := range fd.funcs {
+			if f.name != c.old || f.receiver != "" || family != nil && (f.owner == nil || !family[f.owner.name]) ||
+				r.Signature != nil && !r.Signature.matches(f) {
+				continue
+			}
+			where := packageLabel(fd.pkg)
+			if f.owner != nil {
+				where = f.owner.name
+			}
+			scope := fd.scopeAt(f.nameTok)
+			c.overloadClash(f, func(g *funcDecl, gfd *fileDecls) bool {
+				if f.owner != nil {
+					return g.owner == f.owner
+				}
+				return gfd == fd && gfd.scopeAt(g.nameTok) == scope
+			}, where)
+		}
+	}
+	c.captures(family, true)
+}
+
+// properties checks a property rename: a property new in a declaring class,
+// and unqualified uses that a local or parameter called new would capture.
+func (c *conflictFinder) properties(className string) {
+	if className == "" {
+		return
+	}
+	family := c.ix.memberClasses(className, c.old, true)
+	for _, fd := range c.all() {
+		for _, cls := range fd.classes {
+			if !family[cls.name] {
+				continue
+			}
+			for _, p := range cls.props {
+				if p.name == c.new {
+					c.add(fd, fd.code[p.nameTok].start, "%s already declares property %s", cls.name, c.new)
+				}
+			}
+		}
+	}
+	c.captures(family, false)
+}
+
+// captures reports unqualified uses of the member old, inside the classes
+// in family (or any class, when nil), where a local or parameter called new
+// is in scope and would take over after the rename.
+func (c *conflictFinder) captures(family map[string]bool, call bool) {
+	for _, fd := range c.renamed {
+		p := &parser{fd: fd, code: fd.code}
+		for i, t := range fd.code {
+			if t.kind != tokIdent || unquoteIdent(t.text) != c.old || !bareUse(fd, i) ||
+				(p.text(i+1) == "(") != call {
+				continue
+			}
+			if b := fd.lookup(c.old, i); b != nil && !b.member {
+				continue // a local or parameter, not the member
+			}
+			if family != nil && !insideClasses(fd, i, family) {
+				continue
+			}
+			if b := fd.lookup(c.new, i); b != nil && !b.member {
+				c.add(fd, t.start, "%s here would refer to the local %s declared on line %d", c.new, c.new,
+					positionOf(fd, fd.code[b.tok].start).Line)
+			}
+		}
+	}
+}
+
+// overloadClash reports functions called new that sameScope accepts and
+// that declare the same parameter types as f.
+func (c *conflictFinder) overloadClash(f *funcDecl, sameScope func(g *funcDecl, gfd *fileDecls) bool, where string) {
+	sig := signatureOf(f)
+	for _, gfd := range c.all() {
+		for _, g := range gfd.funcs {
+			if g.name == c.new && sig.matches(g) && sameScope(g, gfd) {
+				c.add(gfd, gfd.code[g.nameTok].start, "%s already declares %s%s", where, c.new, sig)
+			}
+		}
+	}
+}
+
+// extensions checks an extension rename: an extension new on the same
+// receiver with the same parameters, or a member new of the receiver class,
+// which would take precedence over the extension at every call.
+func (c *conflictFinder) extensions(receiver string) {
+	for _, fd := range c.all() {
+		for _, f := range fd.funcs {
+			if f.name != c.old || f.receiver != receiver {
+				continue
+			}
+			c.overloadClash(f, func(g *funcDecl, _ *fileDecls) bool { return g.receiver == receiver }, receiver)
+			for _, g := range c.ix.funcs[c.new] {
+				if g.owner != nil && g.owner.name == receiver && g.receiver == "" && signatureOf(f).matches(g) {
+					c.add(fd, fd.code[f.nameTok].start, "%s declares a member %s%s, which would take precedence over the extension",
+						receiver, c.new, signatureOf(f))
+				}
+			}
+		}
+		for _, p := range fd.props {
+			if p.name != c.old || p.receiver != receiver {
+				continue
+			}
+			for _, other := range c.all() {
+				for _, q := range other.props {
+					if q.name == c.new && q.receiver == receiver {
+						c.add(other, other.code[q.nameTok].start, "extension property %s.%s already exists", receiver, c.new)
+					}
+				}
+			}
+			if declared, _ := c.ix.declaresMember(receiver, c.new, true); declared {
+				c.add(fd, fd.code[p.nameTok].start, "%s declares a member property %s, which would take precedence over the extension",
+					receiver, c.new)
+			}
+		}
+	}
+}
+
+// enumEntries reports an entry new already declared by the enum className.
+func (c *conflictFinder) enumEntries(className string) {
+	for _, fd := range c.all() {
+		for _, cls := range fd.classes {
+			if cls.name != className {
+				continue
+			}
+			for _, e := range cls.entries {
+				if unquoteIdent(fd.code[e].text) == c.new {
+					c.add(fd, fd.code[e].start, "enum class %s already has an entry %s", className, c.new)
+				}
+			}
+		}
+	}
+}
+
+// ─── locals and parameters ────────────────────────────────────────────────────
+
+// parameters checks a parameter rename in each target function: another
+// parameter new, a property new of a constructor's class, and shadowing in
+// the function body.
+func (c *conflictFinder) parameters(r *ParameterRenamer) {
+	r.families = make(map[string]map[string]bool)
+	for _, fd := range c.renamed {
+		var funcs []*funcDecl
+		funcs = append(funcs, fd.funcs...)
+		for _, cls := range fd.classes {
+			funcs = append(funcs, cls.constructors()...)
+		}
+		for _, f := range funcs {
+			if !r.isTarget(f, c.old) {
+				continue
+			}
+			if prm := f.param(c.new); prm != nil {
+				c.add(fd, fd.code[prm.nameTok].start, "%s already has a parameter %s", f.name, c.new)
+			}
+			if f.ctor {
+				for _, p := range f.owner.props {
+					if p.name == c.new {
+						c.add(fd, fd.code[p.nameTok].start, "%s already declares property %s", f.owner.name, c.new)
+					}
+				}
+			}
+			if b := fd.paramBinding(f.param(c.old)); b != nil {
+				c.shadowing(fd, b)
+			}
+		}
+	}
+}
+
+// locals checks a local rename: a variable new in the same scope, and
+// shadowing either way.  Renaming it checks each lambda whose implicit
+// parameter becomes new the same way.
+func (c *conflictFinder) locals(r *LocalRenamer) {
+	for _, fd := range c.renamed {
+		if c.old == "it" {
+			var lambdas []*scope
+			for s := range r.itLambdas(fd) {
+				lambdas = append(lambdas, s)
+			}
+			sort.Slice(lambdas, func(a, b int) bool { return lambdas[a].open < lambdas[b].open })
+			for _, s := range lambdas {
+				c.itParameter(fd, s)
+			}
+		}
+		for b := range r.declarations(fd, c.old) {
+			s := fd.bindingScope(b)
+			for _, other := range s.bindings {
+				if other.name == c.new && !other.member {
+					c.add(fd, fd.code[other.tok].start, "%s is already declared in the same scope", c.new)
+				}
+			}
+			if outer := fd.lookup(c.new, b.tok); outer != nil && !outer.member && fd.bindingScope(outer) != s {
+				c.add(fd, fd.code[b.tok].start, "the renamed %s would shadow %s declared on line %d", c.old, c.new,
+					positionOf(fd, fd.code[outer.tok].start).Line)
+			}
+			c.shadowing(fd, b)
+		}
+	}
+}
+
+// itParameter reports, for the lambda s whose it becomes the parameter new,
+// the declarations of new inside it that would capture its uses, and the
+// uses of another new inside it that the parameter would capture.
+func (c *conflictFinder) itParameter(fd *fileDecls, s *scope) {
+	var nested func(inner *scope)
+	nested = func(inner *scope) {
+		for _, nb := range inner.bindings {
+			if nb.name == c.new && !nb.member {
+				c.add(fd, fd.code[nb.tok].start, "%s declared here would shadow the renamed %s", c.new, c.old)
+			}
+		}
+		for _, child := range inner.children {
+			nested(child)
+		}
+	}
+	nested(s)
+
+	p := &parser{fd: fd, code: fd.code}
+	for i := s.open + 1; i < s.close; i++ {
+		t := fd.code[i]
+		if t.kind != tokIdent || unquoteIdent(t.text) != c.new || !bareUse(fd, i) || p.text(i+1) == "(" {
+			continue
+		}
+		if nb := fd.lookup(c.new, i); nb == nil || !encloses(s, fd.bindingScope(nb)) {
+			c.add(fd, t.start, "%s here refers to another declaration, which the renamed %s would shadow", c.new, c.old)
+		}
+	}
+}
+
+// shadowing reports, for the local or parameter b being renamed, its uses
+// that a nearer declaration called new would capture, and the uses of
+// another new in its scope that it would capture in turn.
+func (c *conflictFinder) shadowing(fd *fileDecls, b *binding) {
+	s := fd.bindingScope(b)
+	var nested func(inner *scope)
+	nested = func(inner *scope) {
+		for _, nb := range inner.bindings {
+			// a local's own scope is a redeclaration, reported by locals
+			if nb.name == c.new && !nb.member && nb.tok > b.tok && (inner != s || b.param != nil) {
+				c.add(fd, fd.code[nb.tok].start, "%s declared here would shadow the renamed %s", c.new, c.old)
+			}
+		}
+		for _, child := range inner.children {
+			nested(child)
+		}
+	}
+	nested(s)
+
+	p := &parser{fd: fd, code: fd.code}
+	for i, t := range fd.code {
+		if i <= s.open || i >= s.close || t.kind != tokIdent || !bareUse(fd, i) {
+			continue
+		}
+		switch unquoteIdent(t.text) {
+		case c.old:
+			if fd.lookup(c.old, i) != b {
+				continue
+			}
+			if nb := fd.lookup(c.new, i); nb != nil && encloses(s, fd.bindingScope(nb)) && (fd.bindingScope(nb) != s || nb.tok > b.tok) {
+				c.add(fd, t.start, "%s here would refer to the %s declared on line %d", c.new, c.new,
+					positionOf(fd, fd.code[nb.tok].start).Line)
+			}
+		case c.new:
+			if i <= b.after || i <= b.tok || p.text(i+1) == "(" || b.initOnly && crossesFunc(fd, i, s) {
+				continue // not yet in scope, a call, or a member function a constructor parameter can't see
+			}
+			if nb := fd.lookup(c.new, i); nb == nil || !encloses(s, fd.bindingScope(nb)) {
+				c.add(fd, t.start, "%s here refers to another declaration, which the renamed %s would shadow", c.new, c.old)
+			}
+		}
+	}
+}
+
+// typeParams checks a type-parameter rename: another type parameter new of
+// the same declaration, and uses of a type new that it would capture.
+func (c *conflictFinder) typeParams(r *TypeParameterRenamer) {
+	for _, fd := range c.renamed {
+		for _, tp := range fd.typeParams {
+			if tp.name != c.old || !r.isTarget(tp) {
+				continue
+			}
+			for _, other := range fd.typeParams {
+				if other.name == c.new && other.class == tp.class && other.fn == tp.fn && other.alias == tp.alias {
+					c.add(fd, fd.code[other.nameTok].start, "the declaration already has a type parameter %s", c.new)
+				}
+			}
+			for i := tp.from; i <= tp.to; i++ {
+				t := fd.code[i]
+				if t.kind == tokIdent && unquoteIdent(t.text) == c.new && bareUse(fd, i) &&
+					fd.lookup(c.new, i) == nil && fd.typeParamAt(t.start, c.new) == nil {
+					c.add(fd, t.start, "%s here refers to another declaration, which the renamed %s would shadow", c.new, c.old)
+				}
+			}
+		}
+	}
+}
+
+// ─── helpers ──────────────────────────────────────────────────────────────────
+
+// bindingScope returns the scope that declares b.
+func (fd *fileDecls) bindingScope(b *binding) *scope {
+	var found *scope
+	var walk func(s *scope)
+	walk = func(s *scope) {
+		for _, other := range s.bindings {
+			if other == b {
+				found = s
+			}
+		}
+		for _, child := range s.children {
+			walk(child)
+		}
+	}
+	walk(fd.root)
+	return found
+}
+
+// paramBinding returns the binding of prm in its function's scope, or nil.
+func (fd *fileDecls) paramBinding(prm *paramDecl) *binding {
+	if prm == nil {
+		return nil
+	}
+	if b := fd.lookup(prm.name, prm.nameTok); b != nil && b.param == prm {
+		return b
+	}
+	return nil
+}
+
+// encloses reports whether inner is outer or nested inside it.
+func encloses(outer, inner *scope) bool {
+	for s := inner; s != nil; s = s.parent {
+		if s == outer {
+			return true
+		}
+	}
+	return false
+}
+
+// crossesFunc reports whether code[i] lies in a function nested inside s.
+func crossesFunc(fd *fileDecls, i int, s *scope) bool {
+	for inner := fd.scopeAt(i); inner != nil && inner != s; inner = inner.parent {
+		if inner.kind == scopeFunc {
+			return true
+		}
+	}
+	return false
+}
+
+// bareUse reports whether code[i] is an unqualified use of a name rather
+// than a declaration, a member access or a named-argument label.
+func bareUse(fd *fileDecls, i int) bool {
+	if _, ok := qualifierBefore(fd.src, fd.code[i].start); ok || declaresName(fd, i) {
+		return false
+	}
+	if _, label := fd.namedArgCallee(i); label {
+		return false
+	}
+	access, _ := fd.receiverEnd(i)
+	return access == accessBare
+}
+
+// insideClasses reports whether code[i] lies in the body of a class in set.
+func insideClasses(fd *fileDecls, i int, set map[string]bool) bool {
+	for cls := fd.classAt(i); cls != nil; cls = cls.outer {
+		if set[cls.name] {
+			return true
+		}
+	}
+	return false
+}
+
+// mentions reports whether fd uses the identifier name in code.
+func mentions(fd *fileDecls, name string) bool {
+	for _, t := range fd.code {
+		if t.kind == tokIdent && unquoteIdent(t.text) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func packageLabel(pkg string) string {
+	if pkg == "" {
+		return "the default package"
+	}
+	return "package " + pkg
+}
@@ -0,0 +1,1187 @@
+package renamer
+
+import (
+	"strings"
+)
+
+// ─── declaration model ────────────────────────────────────────────────────────
+
+// fileDecls is the declaration structure of one Kotlin file: its classes,
+// functions and properties, and the tree of lexical scopes they live in.
+//
+// All positions are indices into code, the file's comment-free token list.
+type fileDecls struct {
+	path string // set when the file was loaded through an Index
+	src  string
+	toks []token
+	code []token
+
+	pkg     string
+	imports []kotlinImport
+
+	classes []*classDecl // every class, interface and object, nested ones included
+	funcs   []*funcDecl  // every named function, members and locals included
+	props   []*propDecl  // every val/var, locals included
+
+	aliases    []*aliasDecl     // every typealias
+	typeParams []*typeParamDecl // every type parameter of a class, function or typealias
+
+	root *scope
+}
+
+// classDecl is a class, interface or object declaration.
+type classDecl struct {
+	name       string // "" for an anonymous object expression
+	kind       string // "class", "interface" or "object"
+	modifiers  []string
+	nameTok    int      // -1 when anonymous
+	supertypes []string // simple names, e.g. "Repository" for ": com.example.Repository<T>"
+	ctorParams []*paramDecl
+	primary    *funcDecl   // the primary constructor, nil without a parameter list
+	ctors      []*funcDecl // secondary constructors
+	headerEnd  int         // index of the last token before the body
+	bodyOpen   int         // index of "{", or -1 when the class has no body
+	bodyClose  int
+	outer      *classDecl
+	funcs      []*funcDecl // direct members
+	props      []*propDecl // direct members, promoted constructor params included
+	entries    []int       // for an enum class: the indices of its entries' names
+}
+
+// funcDecl is a fun declaration or a constructor.  A constructor is named
+// after its class; nameTok is its "constructor" keyword, or the class name
+// for a primary constructor without one.
+type funcDecl struct {
+	name       string // "" for an anonymous function
+	nameTok    int
+	ctor       bool
+	modifiers  []string
+	receiver   string // simple name of the extension receiver type, if any
+	params     []*paramDecl
+	paramsOpen int // index of "("
+	paramsEnd  int // index of ")"
+	bodyStart  int // "{" or "=", or -1 when the function has no body
+	bodyEnd    int
+	owner      *classDecl // the class this is a direct member of, or nil
+	copyOf     *funcDecl  // for the copy() of a data class: its primary constructor
+}
+
+// propDecl is a val/var declaration.
+type propDecl struct {
+	name      string
+	nameTok   int
+	modifiers []string
+	typ       string // declared or inferred simple type name, "" if unknown
+	receiver  string // simple name of the extension receiver type, if any
+	owner     *classDecl
+	local     bool // declared inside a function body or initializer block
+}
+
+// paramDecl is one parameter of a function or primary constructor.
+type paramDecl struct {
+	name       string
+	nameTok    int
+	typ        string
+	hasDefault bool
+	vararg     bool
+	binding    string // "val" or "var" for promoted constructor params
+}
+
+// aliasDecl is a typealias declaration.
+type aliasDecl struct {
+	name    string
+	nameTok int
+}
+
+// typeParamDecl is a type parameter, visible from the "<" of its declaration
+// through the declaration's last token.
+type typeParamDecl struct {
+	name    string
+	nameTok int
+	from    int
+	to      int
+	class   *classDecl // the declaring class, or nil
+	fn      *funcDecl  // the declaring function, or nil
+	alias   *aliasDecl // the declaring typealias, or nil
+}
+
+// ─── scopes ───────────────────────────────────────────────────────────────────
+
+type scopeKind int
+
+const (
+	scopeFile scopeKind = iota
+	scopeClass
+	scopeFunc
+	scopeBlock
+	scopeLambda
+)
+
+// scope is a lexical region.  open and close are the indices of its
+// delimiting tokens ("{" and "}", or "(" and the end of the body for a
+// function); only tokens strictly between them are inside.
+type scope struct {
+	kind     scopeKind
+	open     int
+	close    int
+	parent   *scope
+	children []*scope
+	class    *classDecl // scopeClass
+	fn       *funcDecl  // scopeFunc
+	bindings []*binding
+}
+
+// binding is a name introduced into a scope: a property, parameter or local.
+type binding struct {
+	name string
+	typ  string // simple type name, "" when unknown
+	tok  int
+	// member bindings are visible throughout their scope; others only after
+	// their declaration, and a local only after its initializer (code[after]).
+	member bool
+	after  int
+	// initOnly marks plain constructor parameters, which are visible in
+	// initializers but not inside member functions.
+	initOnly bool
+	prop     *propDecl
+	param    *paramDecl
+}
+
+func (s *scope) contains(i int) bool {
+	return s.open < i && i < s.close
+}
+
+// scopeAt returns the innermost scope containing code index i.
+func (fd *fileDecls) scopeAt(i int) *scope {
+	s := fd.root
+	for {
+		var next *scope
+		for _, c := range s.children {
+			if c.contains(i) {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return s
+		}
+		s = next
+	}
+}
+
+// lookup resolves an unqualified name used at code index i to the binding it
+// refers to, or nil if no enclosing scope in this file declares it.  At a
+// declaration's own name token, lookup returns that declaration.
+func (fd *fileDecls) lookup(name string, i int) *binding {
+	crossedFunc := false
+	for s := fd.scopeAt(i); s != nil; s = s.parent {
+		for k := len(s.bindings) - 1; k >= 0; k-- {
+			b := s.bindings[k]
+			if b.name != name || (b.initOnly && crossedFunc) {
+				continue
+			}
+			if b.member || b.tok == i || b.tok < i && i > b.after {
+				return b
+			}
+		}
+		if s.kind == scopeFunc {
+			crossedFunc = true
+		}
+	}
+	return nil
+}
+
+// classAt returns the innermost class whose body contains code index i.
+func (fd *fileDecls) classAt(i int) *classDecl {
+	for s := fd.scopeAt(i); s != nil; s = s.parent {
+		if s.kind == scopeClass {
+			return s.class
+		}
+	}
+	return nil
+}
+
+// thisType returns the simple name of the type "this" denotes at code
+// index i: the receiver of the innermost extension function or the
+// innermost class, whichever is closer.  "" outside both and in anonymous
+// objects.
+func (fd *fileDecls) thisType(i int) string {
+	for s := fd.scopeAt(i); s != nil; s = s.parent {
+		switch {
+		case s.kind == scopeFunc && s.fn != nil && s.fn.receiver != "":
+			return s.fn.receiver
+		case s.kind == scopeClass:
+			if s.class == nil {
+				return ""
+			}
+			return s.class.name
+		}
+	}
+	return ""
+}
+
+// extensionReceivers returns the receivers of the extension functions
+// enclosing code index i inside its innermost class, innermost first.  They
+// are implicit receivers that come before the class's own members.
+func (fd *fileDecls) extensionReceivers(i int) []string {
+	var out []string
+	for s := fd.scopeAt(i); s != nil && s.kind != scopeClass; s = s.parent {
+		if s.kind == scopeFunc && s.fn != nil && s.fn.receiver != "" {
+			out = append(out, s.fn.receiver)
+		}
+	}
+	return out
+}
+
+// headerAt returns the class whose primary constructor parameters or
+// supertype list contain code index i.
+func (fd *fileDecls) headerAt(i int) *classDecl {
+	for _, c := range fd.classes {
+		if c.primary != nil && c.primary.paramsOpen < i && i <= c.headerEnd {
+			return c
+		}
+	}
+	return nil
+}
+
+// funcAt returns the function declared with its name at code index i.
+func (fd *fileDecls) funcAt(i int) *funcDecl {
+	for _, f := range fd.funcs {
+		if f.nameTok == i {
+			return f
+		}
+	}
+	return nil
+}
+
+// codeIndex returns the index of the code token starting at byte offset pos,
+// or -1.
+func (fd *fileDecls) codeIndex(pos int) int {
+	i := tokenAt(fd.code, pos)
+	if i < 0 || fd.code[i].start != pos {
+		return -1
+	}
+	return i
+}
+
+// param returns f's parameter called name, or nil.
+func (f *funcDecl) param(name string) *paramDecl {
+	for _, prm := range f.params {
+		if prm.name == name {
+			return prm
+		}
+	}
+	return nil
+}
+
+// constructors returns the primary constructor, if any, followed by the
+// secondary ones.
+func (c *classDecl) constructors() []*funcDecl {
+	if c.primary == nil {
+		return c.ctors
+	}
+	return append([]*funcDecl{c.primary}, c.ctors...)
+}
+
+// acceptsArgs reports whether a call passing n arguments can resolve to f.
+func (f *funcDecl) acceptsArgs(n int) bool {
+	if f.copyOf != nil {
+		return n <= len(f.params) // every copy() parameter has a default
+	}
+	required := 0
+	for _, prm := range f.params {
+		if prm.vararg {
+			return n >= required
+		}
+		if !prm.hasDefault {
+			required++
+		}
+	}
+	return n >= required && n <= len(f.params)
+}
+
+// ─── parser ───────────────────────────────────────────────────────────────────
+
+// parseFile builds the declaration structure of src.  The parser is
+// deliberately forgiving: anything it does not recognise is skipped, and an
+// unbalanced bracket ends the enclosing construct at end of input.
+func parseFile(src string) *fileDecls {
+	toks := tokenize(src)
+	fd := &fileDecls{
+		src:     src,
+		toks:    toks,
+		code:    codeTokens(toks),
+		pkg:     extractPackage(src),
+		imports: parseImports(src),
+	}
+	fd.root = &scope{kind: scopeFile, open: -1, close: len(fd.code)}
+
+	p := &parser{fd: fd, code: fd.code}
+	p.parseRange(fd.root, 0, len(fd.code))
+	return fd
+}
+
+type parser struct {
+	fd   *fileDecls
+	code []token
+}
+
+func (p *parser) text(i int) string {
+	if i < 0 || i >= len(p.code) {
+		return ""
+	}
+	return p.code[i].text
+}
+
+func (p *parser) isIdent(i int) bool {
+	return i >= 0 && i < len(p.code) && p.code[i].kind == tokIdent
+}
+
+// closeOf returns the index of the bracket matching code[i], clamped to
+// limit-1 when the brackets are unbalanced.
+func (p *parser) closeOf(i, limit int) int {
+	j := matchingToken(p.code, i)
+	if j < 0 || j >= limit {
+		return limit - 1
+	}
+	return j
+}
+
+// newlineBefore reports whether a line break separates code[i-1] and code[i].
+func (p *parser) newlineBefore(i int) bool {
+	if i <= 0 || i >= len(p.code) {
+		return true
+	}
+	return strings.Contains(p.fd.src[p.code[i-1].end:p.code[i].start], "\n")
+}
+
+// afterMemberAccess reports whether code[i] directly follows "." or "::",
+// i.e. it is a member name rather than a keyword.
+func (p *parser) afterMemberAccess(i int) bool {
+	return p.text(i-1) == "." || (p.text(i-1) == ":" && p.text(i-2) == ":")
+}
+
+func (p *parser) newScope(parent *scope, kind scopeKind, open, close int) *scope {
+	s := &scope{kind: kind, open: open, close: close, parent: parent}
+	parent.children = append(parent.children, s)
+	return s
+}
+
+func (p *parser) parseRange(s *scope, from, to int) {
+	for i := from; i < to; i++ {
+		t := p.code[i]
+		switch {
+		case t.text == "{":
+			j := p.closeOf(i, to)
+			kind := p.blockKind(i)
+			child := p.newScope(s, kind, i, j)
+			if kind == scopeLambda {
+				p.lambdaParams(child, i, j)
+			}
+			p.parseRange(child, i+1, j)
+			i = j
+
+		case t.kind != tokIdent || p.afterMemberAccess(i):
+			// not a keyword
+
+		case p.isClassKeyword(i):
+			i = p.parseClass(s, i, to)
+
+		case t.text == "fun" && p.text(i+1) != "interface":
+			i = p.parseFun(s, i, to)
+
+		case t.text == "constructor" && p.text(i+1) == "(" && s.kind == scopeClass:
+			i = p.parseConstructor(s, i, to)
+
+		case t.text == "val" || t.text == "var":
+			i = p.parseProperty(s, i, to)
+
+		case t.text == "for" && p.text(i+1) == "(":
+			i = p.parseFor(s, i, to)
+
+		case t.text == "typealias" && p.isIdent(i+1):
+			i = p.parseTypeAlias(i, to)
+		}
+	}
+}
+
+// blockKind classifies the block opened by code[open] as a lambda or a plain
+// statement block.  Class and function bodies are handled by their parsers.
+func (p *parser) blockKind(open int) scopeKind {
+	switch p.text(open - 1) {
+	case "else", "try", "finally", "do", "init", "when":
+		return scopeBlock
+	case ">":
+		if p.text(open-2) == "-" {
+			return scopeBlock // when branch: cond -> { ... }
+		}
+	case ")":
+		o := matchingOpen(p.code, open-1)
+		switch p.text(o - 1) {
+		case "if", "for", "while", "catch", "when", "get", "set", "constructor":
+			return scopeBlock
+		}
+	}
+	return scopeLambda
+}
+
+// lambdaParams binds the parameters declared before "->" in the lambda
+// whose braces are code[open] and code[close].
+func (p *parser) lambdaParams(s *scope, open, close int) {
+	arrow := p.lambdaArrow(open, close)
+	if arrow < 0 {
+		return
+	}
+
+	for _, seg := range splitTopLevel(p.code, open+1, arrow) {
+		from, to := seg[0], seg[1]
+		if from >= to {
+			continue
+		}
+		if p.text(from) == "(" {
+			// destructuring: (key, value)
+			for k := from + 1; k < to; k++ {
+				if p.isIdent(k) && p.text(k-1) != ":" && p.text(k) != "_" {
+					s.bindings = append(s.bindings, &binding{name: p.text(k), tok: k})
+				}
+			}
+			continue
+		}
+		if p.isIdent(from) && p.text(from) != "_" {
+			b := &binding{name: p.text(from), tok: from}
+			if p.text(from+1) == ":" && from+2 < to {
+				b.typ = simpleTypeName(p.fd.src[p.code[from+2].start:p.code[to-1].end])
+			}
+			s.bindings = append(s.bindings, b)
+		}
+	}
+}
+
+// lambdaArrow returns the index of the "-" of the "->" ending the parameter
+// list of the lambda whose braces are code[open] and code[close], or -1 when
+// it declares no parameters.
+func (p *parser) lambdaArrow(open, close int) int {
+	depth := 0
+	for i := open + 1; i < close; i++ {
+		t := p.code[i]
+		if t.text == "-" && p.text(i+1) == ">" && depth == 0 {
+			return i
+		}
+		switch {
+		case t.text == "(" || t.text == "<":
+			depth++
+		case t.text == ")" || t.text == ">":
+			depth--
+		case t.kind == tokIdent, t.text == ",", t.text == ":", t.text == ".", t.text == "?":
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+var classModifiers = map[string]bool{
+	"data": true, "enum": true, "sealed": true, "abstract": true, "open": true,
+	"inner": true, "annotation": true, "value": true, "inline": true, "private": true,
+	"protected": true, "internal": true, "public": true, "companion": true, "fun": true,
+	"expect": true, "actual": true, "external": true, "final": true, "override": true,
+	"suspend": true, "const": true, "lateinit": true, "tailrec": true, "operator": true,
+	"infix": true,
+}
+
+// modifiersBefore collects the modifier keywords immediately preceding
+// code[i].
+func (p *parser) modifiersBefore(i int) []string {
+	var mods []string
+	for k := i - 1; k >= 0 && p.isIdent(k) && classModifiers[p.text(k)] && !p.afterMemberAccess(k); k-- {
+		mods = append(mods, p.text(k))
+	}
+	return mods
+}
+
+func (p *parser) isClassKeyword(i int) bool {
+	switch p.text(i) {
+	case "class", "interface":
+		return p.isIdent(i + 1)
+	case "object":
+		return true
+	}
+	return false
+}
+
+// parseClass parses the class, interface or object declaration whose keyword
+// is code[i] and returns the index of its last token.
+func (p *parser) parseClass(s *scope, i, to int) int {
+	cd := &classDecl{kind: p.text(i), modifiers: p.modifiersBefore(i), nameTok: -1, bodyOpen: -1, bodyClose: -1}
+	cd.outer = p.fd.classOf(s)
+
+	j := i + 1
+	if p.isIdent(j) && p.text(j) != "by" {
+		cd.name = unquoteIdent(p.text(j))
+		cd.nameTok = j
+		j++
+	} else if hasString(cd.modifiers, "companion") {
+		cd.name = "Companion"
+	}
+
+	var typeParams []int
+	typeOpen := j
+	if p.text(j) == "<" {
+		typeParams = p.typeParamNames(j)
+		j = p.skipAngles(j)
+	}
+
+	// primary constructor: [modifiers] [@Annotations] [constructor] (...)
+	for !p.newlineBefore(j) && (p.isIdent(j) && (classModifiers[p.text(j)] || p.text(j) == "constructor") || p.text(j) == "@") {
+		if p.text(j) == "@" {
+			j = p.skipAnnotation(j)
+			continue
+		}
+		j++
+	}
+	if p.text(j) == "(" && !p.newlineBefore(j) {
+		close := p.closeOf(j, to)
+		cd.ctorParams = p.parseParams(j, close)
+		cd.primary = &funcDecl{name: cd.name, nameTok: cd.nameTok, ctor: true, params: cd.ctorParams,
+			paramsOpen: j, paramsEnd: close, bodyStart: -1, bodyEnd: -1, owner: cd}
+		j = close + 1
+	}
+
+	// supertypes: : Base(), Iface, Other<T> by delegate
+	if p.text(j) == ":" {
+		j++
+		for j < to {
+			for p.text(j) == "@" {
+				j = p.skipAnnotation(j)
+			}
+			start := j
+			j = p.skipType(j)
+			if j == start {
+				break
+			}
+			cd.supertypes = append(cd.supertypes, simpleTypeName(p.fd.src[p.code[start].start:p.code[j-1].end]))
+			if p.text(j) == "(" {
+				j = p.closeOf(j, to) + 1
+			}
+			if p.text(j) == "by" {
+				j = expressionEnd(p.fd.src, p.code[:to], j+1) + 1
+			}
+			if p.text(j) != "," {
+				break
+			}
+			j++
+		}
+	}
+
+	// where T : Bound, U : Other
+	if p.text(j) == "where" {
+		for j < to && p.text(j) != "{" && !(p.newlineBefore(j) && p.isIdent(j) && p.text(j-1) != ",") {
+			j++
+		}
+	}
+
+	cd.headerEnd = j - 1
+	p.fd.classes = append(p.fd.classes, cd)
+
+	if p.text(j) != "{" {
+		p.bindCtorParams(cd, nil)
+		p.addTypeParams(typeParams, typeOpen, j-1, &typeParamDecl{class: cd})
+		return j - 1
+	}
+
+	cd.bodyOpen = j
+	cd.bodyClose = p.closeOf(j, to)
+	if cd.primary != nil {
+		cd.primary.bodyStart, cd.primary.bodyEnd = cd.bodyOpen, cd.bodyClose
+	}
+	if hasString(cd.modifiers, "enum") {
+		cd.entries = p.enumEntries(cd.bodyOpen+1, cd.bodyClose)
+	}
+	body := p.newScope(s, scopeClass, cd.bodyOpen, cd.bodyClose)
+	body.class = cd
+	p.bindCtorParams(cd, body)
+	p.parseRange(body, cd.bodyOpen+1, cd.bodyClose)
+	p.addTypeParams(typeParams, typeOpen, cd.bodyClose, &typeParamDecl{class: cd})
+	return cd.bodyClose
+}
+
+// enumEntries returns the indices of the entry names at the start of an enum
+// class body: NAME [(args)] [{ body }], separated by commas and ended by ";"
+// or the end of the body.
+func (p *parser) enumEntries(from, to int) []int {
+	var entries []int
+	for j := from; j < to; j++ {
+		for p.text(j) == "@" {
+			j = p.skipAnnotation(j)
+		}
+		if !p.isIdent(j) {
+			break
+		}
+		entries = append(entries, j)
+		if p.text(j+1) == "(" {
+			j = p.closeOf(j+1, to)
+		}
+		if p.text(j+1) == "{" {
+			j = p.closeOf(j+1, to)
+		}
+		if p.text(j+1) != "," {
+			break
+		}
+		j++
+	}
+	return entries
+}
+
+// bindCtorParams records promoted constructor parameters as properties and
+// binds every constructor parameter in the class body.
+func (p *parser) bindCtorParams(cd *classDecl, body *scope) {
+	for _, prm := range cd.ctorParams {
+		b := &binding{name: prm.name, typ: prm.typ, tok: prm.nameTok, member: true, param: prm}
+		if prm.binding != "" {
+			pd := &propDecl{name: prm.name, nameTok: prm.nameTok, typ: prm.typ, owner: cd, modifiers: []string{prm.binding}}
+			cd.props = append(cd.props, pd)
+			p.fd.props = append(p.fd.props, pd)
+			b.prop = pd
+		} else {
+			b.initOnly = true
+		}
+		if body != nil {
+			body.bindings = append(body.bindings, b)
+		}
+	}
+}
+
+// parseFun parses the function declaration whose "fun" keyword is code[i]
+// and returns the index of its last token.
+func (p *parser) parseFun(s *scope, i, to int) int {
+	fd := &funcDecl{nameTok: -1, modifiers: p.modifiersBefore(i), bodyStart: -1, bodyEnd: -1}
+
+	j := i + 1
+	var typeParams []int
+	typeOpen := j
+	if p.text(j) == "<" {
+		typeParams = p.typeParamNames(j)
+		j = p.skipAngles(j)
+	}
+
+	// [Receiver.]name(  — the name is the identifier right before "("
+	open := -1
+	for k := j; k < to; k++ {
+		switch p.text(k) {
+		case "<":
+			k = p.skipAngles(k) - 1
+			continue
+		case "(":
+			open = k
+		case "{", "}", "=", ";":
+			return k - 1
+		}
+		if open >= 0 {
+			break
+		}
+	}
+	if open < 0 {
+		return to - 1
+	}
+	if open > j && p.isIdent(open-1) {
+		fd.name = unquoteIdent(p.text(open - 1))
+		fd.nameTok = open - 1
+		if open-2 > j && p.text(open-2) == "." {
+			fd.receiver = simpleTypeName(p.fd.src[p.code[j].start:p.code[open-3].end])
+		}
+	}
+
+	fd.paramsOpen = open
+	fd.paramsEnd = p.closeOf(open, to)
+	fd.params = p.parseParams(open, fd.paramsEnd)
+	if s.kind == scopeClass {
+		fd.owner = s.class
+		s.class.funcs = append(s.class.funcs, fd)
+	}
+	if fd.name != "" {
+		p.fd.funcs = append(p.fd.funcs, fd)
+	}
+
+	end := fd.paramsEnd
+	if start, stop := functionBody(p.fd.src, p.code[:to], fd.paramsEnd+1); start >= 0 {
+		fd.bodyStart, fd.bodyEnd = start, stop
+		end = stop
+	}
+
+	fs := p.newScope(s, scopeFunc, fd.paramsOpen, end+1)
+	fs.fn = fd
+	for _, prm := range fd.params {
+		fs.bindings = append(fs.bindings, &binding{name: prm.name, typ: prm.typ, tok: prm.nameTok, param: prm})
+	}
+
+	switch {
+	case fd.bodyStart < 0:
+		p.addTypeParams(typeParams, typeOpen, p.signatureEnd(fd.paramsEnd, to), &typeParamDecl{fn: fd})
+	case p.text(fd.bodyStart) == "{":
+		body := p.newScope(fs, scopeBlock, fd.bodyStart, fd.bodyEnd)
+		p.parseRange(body, fd.bodyStart+1, fd.bodyEnd)
+	default:
+		p.parseRange(fs, fd.bodyStart+1, fd.bodyEnd+1)
+	}
+	if fd.bodyStart >= 0 {
+		p.addTypeParams(typeParams, typeOpen, end, &typeParamDecl{fn: fd})
+	}
+	return end
+}
+
+// signatureEnd returns the index of the last token of the return type and
+// where clause following the parameter list that closes at code[close].
+func (p *parser) signatureEnd(close, to int) int {
+	j := close + 1
+	if p.text(j) == ":" {
+		j = p.skipType(j + 1)
+	}
+	if p.text(j) == "where" {
+		for j++; j < to && p.isIdent(j) && p.text(j+1) == ":"; j++ {
+			j = p.skipType(j + 2)
+			if p.text(j) != "," {
+				break
+			}
+		}
+	}
+	return j - 1
+}
+
+// parseTypeAlias parses the typealias declaration whose keyword is code[i]
+// and returns the index of its last token.
+func (p *parser) parseTypeAlias(i, to int) int {
+	ad := &aliasDecl{name: unquoteIdent(p.text(i + 1)), nameTok: i + 1}
+	p.fd.aliases = append(p.fd.aliases, ad)
+
+	j := i + 2
+	var typeParams []int
+	if p.text(j) == "<" {
+		typeParams = p.typeParamNames(j)
+		j = p.skipAngles(j)
+	}
+	end := j - 1
+	if p.text(j) == "=" {
+		end = p.skipType(j+1) - 1
+	}
+	p.addTypeParams(typeParams, i+2, end, &typeParamDecl{alias: ad})
+	return end
+}
+
+// typeParamNames returns the indices of the names declared in the type
+// parameter list opened by code[open]: <T>, <in K, out V : Any>, <reified T>.
+func (p *parser) typeParamNames(open int) []int {
+	close := p.skipAngles(open) - 1
+	var names []int
+	for _, seg := range splitTopLevel(p.code, open+1, close) {
+		j := seg[0]
+		for j < seg[1] && (p.text(j) == "@" || p.text(j) == "in" || p.text(j) == "out" || p.text(j) == "reified") {
+			if p.text(j) == "@" {
+				j = p.skipAnnotation(j)
+				continue
+			}
+			j++
+		}
+		if j < seg[1] && p.isIdent(j) {
+			names = append(names, j)
+		}
+	}
+	return names
+}
+
+// addTypeParams records the type parameters named at code[names], declared
+// by the list opened at code[from] and visible through code[to].  decl says
+// which declaration they belong to.
+func (p *parser) addTypeParams(names []int, from, to int, decl *typeParamDecl) {
+	for _, k := range names {
+		tp := *decl
+		tp.name, tp.nameTok, tp.from, tp.to = unquoteIdent(p.text(k)), k, from, to
+		p.fd.typeParams = append(p.fd.typeParams, &tp)
+	}
+}
+
+// parseConstructor parses the secondary constructor whose "constructor"
+// keyword is code[i], including its this(...) / super(...) delegation, and
+// returns the index of its last token.
+func (p *parser) parseConstructor(s *scope, i, to int) int {
+	f := &funcDecl{name: s.class.name, nameTok: i, ctor: true, modifiers: p.modifiersBefore(i),
+		paramsOpen: i + 1, bodyStart: -1, bodyEnd: -1, owner: s.class}
+	f.paramsEnd = p.closeOf(f.paramsOpen, to)
+	f.params = p.parseParams(f.paramsOpen, f.paramsEnd)
+	s.class.ctors = append(s.class.ctors, f)
+
+	end := f.paramsEnd
+	if p.text(end+1) == ":" && (p.text(end+2) == "this" || p.text(end+2) == "super") && p.text(end+3) == "(" {
+		end = p.closeOf(end+3, to)
+	}
+	if start, stop := functionBody(p.fd.src, p.code[:to], end+1); start >= 0 && p.text(start) == "{" {
+		f.bodyStart, f.bodyEnd = start, stop
+	}
+
+	fs := p.newScope(s, scopeFunc, f.paramsOpen, end+1)
+	if f.bodyStart >= 0 {
+		fs.close = f.bodyEnd + 1
+	}
+	fs.fn = f
+	for _, prm := range f.params {
+		fs.bindings = append(fs.bindings, &binding{name: prm.name, typ: prm.typ, tok: prm.nameTok, param: prm})
+	}
+	p.parseRange(fs, f.paramsEnd+1, end+1)
+	if f.bodyStart < 0 {
+		return end
+	}
+	body := p.newScope(fs, scopeBlock, f.bodyStart, f.bodyEnd)
+	p.parseRange(body, f.bodyStart+1, f.bodyEnd)
+	return f.bodyEnd
+}
+
+// parseProperty parses the val/var declaration whose keyword is code[i]
+// and returns the index of the last token of its name (and type), so the
+// initializer is parsed by the caller like any other expression.
+func (p *parser) parseProperty(s *scope, i, to int) int {
+	mods := append(p.modifiersBefore(i), p.text(i))
+	local := s.kind != scopeClass && s.kind != scopeFile
+	member := !local
+
+	j := i + 1
+	if p.text(j) == "<" {
+		j = p.skipAngles(j)
+	}
+
+	// destructuring: val (a, b) = pair
+	if p.text(j) == "(" {
+		close := p.closeOf(j, to)
+		for k := j + 1; k < close; k++ {
+			if p.isIdent(k) && p.text(k-1) != ":" && p.text(k) != "_" {
+				pd := &propDecl{name: p.text(k), nameTok: k, modifiers: mods, local: local}
+				if !local {
+					pd.owner = p.fd.classOf(s)
+				}
+				p.fd.props = append(p.fd.props, pd)
+				s.bindings = append(s.bindings, &binding{name: pd.name, tok: k, member: member, prop: pd,
+					after: p.initializerEnd(close, to)})
+			}
+		}
+		return close
+	}
+
+	if !p.isIdent(j) {
+		return i
+	}
+
+	pd := &propDecl{modifiers: mods, local: local}
+	name := j
+	// skipType reads List<Order>.total as one dotted type: its last segment
+	// is the property's name, the rest the receiver (String?.total stops
+	// at the "." instead)
+	switch end := p.skipType(j); {
+	case p.text(end) == "." && p.isIdent(end+1):
+		pd.receiver = simpleTypeName(p.fd.src[p.code[j].start:p.code[end-1].end])
+		name = end + 1
+	case end-1 > j && p.text(end-2) == "." && p.isIdent(end-1):
+		pd.receiver = simpleTypeName(p.fd.src[p.code[j].start:p.code[end-3].end])
+		name = end - 1
+	}
+	pd.name = unquoteIdent(p.text(name))
+	pd.nameTok = name
+	last := name
+
+	switch p.text(name + 1) {
+	case ":":
+		end := p.skipType(name + 2)
+		if end > name+2 {
+			pd.typ = simpleTypeName(p.fd.src[p.code[name+2].start:p.code[end-1].end])
+			last = end - 1
+		}
+	case "=":
+		pd.typ = p.inferExprType(name+2, to)
+	}
+
+	if !local {
+		pd.owner = p.fd.classOf(s)
+		if pd.owner != nil && s.kind == scopeClass {
+			pd.owner.props = append(pd.owner.props, pd)
+		}
+	}
+	p.fd.props = append(p.fd.props, pd)
+	b := &binding{name: pd.name, typ: pd.typ, tok: name, member: member, prop: pd}
+	if local {
+		b.after = p.initializerEnd(last, to)
+	}
+	s.bindings = append(s.bindings, b)
+	return last
+}
+
+// initializerEnd returns the index of the last token of the initializer
+// following code[last] (" = expr"), or last when there is none.  In
+// val total = total + 1, the initializer still sees the outer total.
+func (p *parser) initializerEnd(last, to int) int {
+	if p.text(last+1) != "=" {
+		return last
+	}
+	return expressionEnd(p.fd.src, p.code[:to], last+2)
+}
+
+// parseFor parses the for loop whose keyword is code[i], binding its loop
+// variables — for (item in items) / for ((k, v) in map) — in a scope that
+// spans the loop header and body, and returns the index of its last token.
+func (p *parser) parseFor(s *scope, i, to int) int {
+	open := i + 1
+	close := p.closeOf(open, to)
+	in := -1
+	for k := open + 1; k < close; k++ {
+		if p.text(k) == "(" {
+			k = p.closeOf(k, close)
+			continue
+		}
+		if p.text(k) == "in" {
+			in = k
+			break
+		}
+	}
+	if in < 0 {
+		return i
+	}
+
+	end := close
+	if p.text(close+1) == "{" {
+		end = p.closeOf(close+1, to)
+	} else if close+1 < to {
+		end = expressionEnd(p.fd.src, p.code[:to], close+1)
+	}
+	fs := p.newScope(s, scopeBlock, open, end+1)
+
+	for k := open + 1; k < in; k++ {
+		if p.isIdent(k) && p.text(k-1) != ":" && p.text(k) != "_" {
+			pd := &propDecl{name: unquoteIdent(p.text(k)), nameTok: k, modifiers: []string{"val"}, local: true}
+			if p.text(k+1) == ":" {
+				if e := p.skipType(k + 2); e > k+2 {
+					pd.typ = simpleTypeName(p.fd.src[p.code[k+2].start:p.code[e-1].end])
+					k = e - 1
+				}
+			}
+			p.fd.props = append(p.fd.props, pd)
+			fs.bindings = append(fs.bindings, &binding{name: pd.name, typ: pd.typ, tok: pd.nameTok, prop: pd, after: close})
+		}
+	}
+	p.parseRange(fs, in+1, end+1)
+	return end
+}
+
+// parseParams parses the parameter list between code[open] "(" and
+// code[close] ")".
+func (p *parser) parseParams(open, close int) []*paramDecl {
+	var params []*paramDecl
+	for _, seg := range splitTopLevel(p.code, open+1, close) {
+		j, to := seg[0], seg[1]
+		prm := &paramDecl{nameTok: -1}
+		for j < to {
+			switch t := p.text(j); {
+			case t == "@":
+				j = p.skipAnnotation(j)
+				continue
+			case t == "vararg":
+				prm.vararg = true
+			case t == "val" || t == "var":
+				prm.binding = t
+			case classModifiers[t] || t == "noinline" || t == "crossinline":
+			default:
+				goto name
+			}
+			j++
+		}
+	name:
+		if j >= to || !p.isIdent(j) {
+			continue
+		}
+		prm.name = unquoteIdent(p.text(j))
+		prm.nameTok = j
+		if p.text(j+1) == ":" && j+2 < to {
+			end := j + 2
+			for end < to && p.text(end) != "=" {
+				end++
+			}
+			if end > j+2 {
+				prm.typ = simpleTypeName(p.fd.src[p.code[j+2].start:p.code[end-1].end])
+			}
+			prm.hasDefault = end < to
+		}
+		params = append(params, prm)
+	}
+	return params
+}
+
+// skipType returns the index just past the type starting at code[i]:
+// dotted names, type arguments, nullability and function types.
+func (p *parser) skipType(i int) int {
+	j := i
+	if p.text(j) == "(" {
+		// function type: (A, B) -> C
+		j = matchingToken(p.code, j)
+		if j < 0 {
+			return i
+		}
+		j++
+		if p.text(j) == "-" && p.text(j+1) == ">" {
+			return p.skipType(j + 2)
+		}
+		return j
+	}
+	for p.isIdent(j) {
+		j++
+		if p.text(j) == "<" {
+			j = p.skipAngles(j)
+		}
+		if p.text(j) == "." && p.isIdent(j+1) && !p.newlineBefore(j) {
+			j++
+			continue
+		}
+		break
+	}
+	for p.text(j) == "?" {
+		j++
+	}
+	return j
+}
+
+// skipAngles returns the index just past the <...> group opening at
+// code[i].
+func (p *parser) skipAngles(i int) int {
+	depth := 0
+	for j := i; j < len(p.code); j++ {
+		switch p.text(j) {
+		case "<":
+			depth++
+		case ">":
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		case "{", "}", ";", "=":
+			return j
+		}
+	}
+	return len(p.code)
+}
+
+// skipAnnotation returns the index just past the annotation whose "@" is
+// code[i]: @Name, @a.b.Name, @Name(args), @field:Name.
+func (p *parser) skipAnnotation(i int) int {
+	j := i + 1
+	if p.isIdent(j) && p.text(j+1) == ":" && p.text(j+2) != ":" {
+		j += 2 // use-site target
+	}
+	for p.isIdent(j) {
+		j++
+		if p.text(j) == "." && p.isIdent(j+1) {
+			j++
+			continue
+		}
+		break
+	}
+	if p.text(j) == "(" && !p.newlineBefore(j) {
+		if k := matchingToken(p.code, j); k >= 0 {
+			j = k + 1
+		}
+	}
+	return j
+}
+
+// classOf returns the class whose body is s or encloses it.
+func (fd *fileDecls) classOf(s *scope) *classDecl {
+	for ; s != nil; s = s.parent {
+		if s.kind == scopeClass {
+			return s.class
+		}
+	}
+	return nil
+}
+
+// ─── helpers ──────────────────────────────────────────────────────────────────
+
+// splitTopLevel splits code[from:to] at commas outside brackets and returns
+// the [start, end) index pairs of the pieces.
+func splitTopLevel(code []token, from, to int) [][2]int {
+	var segs [][2]int
+	depth := 0
+	start := from
+	for i := from; i < to; i++ {
+		switch code[i].text {
+		case "(", "[", "{", "<":
+			depth++
+		case ")", "]", "}", ">":
+			if depth > 0 {
+				depth--
+			}
+		case ",":
+			if depth == 0 {
+				segs = append(segs, [2]int{start, i})
+				start = i + 1
+			}
+		}
+	}
+	if start < to {
+		segs = append(segs, [2]int{start, to})
+	}
+	return segs
+}
+
+// matchingOpen returns the index of the bracket opening the one that
+// code[close] closes, or -1.
+func matchingOpen(code []token, close int) int {
+	var openCh, closeCh string
+	switch code[close].text {
+	case ")":
+		openCh, closeCh = "(", ")"
+	case "]":
+		openCh, closeCh = "[", "]"
+	case "}":
+		openCh, closeCh = "{", "}"
+	default:
+		return -1
+	}
+	depth := 0
+	for i := close; i >= 0; i-- {
+		switch code[i].text {
+		case closeCh:
+			depth++
+		case openCh:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// simpleTypeName reduces a written type to the simple name of its
+// classifier: "com.example.User<T>?" → "User".  Function types are returned
+// as written.
+func simpleTypeName(typ string) string {
+	typ = strings.TrimSpace(typ)
+	if strings.HasPrefix(typ, "(") {
+		return typ
+	}
+	for strings.HasPrefix(typ, "@") {
+		i := strings.IndexAny(typ, " \t")
+		if i < 0 {
+			return ""
+		}
+		typ = strings.TrimSpace(typ[i:])
+	}
+	if i := strings.Index(typ, "<"); i >= 0 {
+		typ = typ[:i]
+	}
+	typ = strings.TrimRight(typ, "? \t")
+	_, name := splitFQN(typ)
+	return unquoteIdent(name)
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package renamer
+
+// EnumEntryRenamer renames an entry of the enum class ClassName.
+//
+// Contexts handled:
+//   - declaration:     enum class Status { PENDING, ... }
+//   - qualified use:   Status.PENDING  /  com.example.Status.PENDING  (when
+//     branches included)
+//   - import:          import com.example.Status.PENDING [as alias]
+//   - unqualified use: PENDING inside the enum class, or in files that import
+//     the entry or Status.*
+//
+// Entries of other enums sharing the name, and locals or parameters that
+// shadow it, are left alone.  Status.valueOf("PENDING") and
+// enumValueOf<Status>("PENDING") name the entry in a string; they are
+// reported via Warnings rather than renamed.  entries and values() need no
+// change.
+type EnumEntryRenamer struct {
+	MatchOptions
+	ClassName string // simple name of the enum class
+	reporter
+}
+
+func (r *EnumEntryRenamer) Rename(content, oldName, newName string) (string, int) {
+	r.reset()
+	fd := parseFile(content)
+	visible := r.visible(fd, oldName)
+
+	out, n := singlePassRename(content, oldName, newName, r.MatchOptions, func(src string, start, end int) bool {
+		if start > 0 && isIdentChar(src[start-1]) || end < len(src) && isIdentChar(src[end]) {
+			return false
+		}
+		if imp, ok := importAt(fd.imports, start); ok {
+			return r.importsEntry(imp, start, oldName)
+		}
+		if qualifier, ok := qualifierBefore(src, start); ok {
+			_, last := splitFQN(qualifier)
+			return last == r.ClassName
+		}
+		return r.refersToEntry(fd, visible, start, oldName)
+	})
+	if !r.IncludeStrings {
+		r.warnValueOf(fd, oldName)
+	}
+	return out, n
+}
+
+// importsEntry reports whether the identifier at pos is the last segment of
+// an import of ClassName.name.
+func (r *EnumEntryRenamer) importsEntry(imp kotlinImport, pos int, name string) bool {
+	owner, last := splitFQN(imp.path)
+	_, class := splitFQN(owner)
+	return !imp.wildcard && class == r.ClassName && last == name && pos == imp.nameStart
+}
+
+// visible reports whether the simple name refers to the entry outside the
+// enum class: an import of the entry itself, or a wildcard import of the
+// enum's entries, with no other explicit import of the name.
+func (r *EnumEntryRenamer) visible(fd *fileDecls, name string) bool {
+	for _, imp := range fd.imports {
+		if !imp.wildcard && imp.name() == name {
+			return imp.alias == "" && r.importsEntry(imp, imp.nameStart, name)
+		}
+	}
+	for _, imp := range fd.imports {
+		if _, class := splitFQN(imp.path); imp.wildcard && class == r.ClassName {
+			return true
+		}
+	}
+	return false
+}
+
+// refersToEntry decides whether the unqualified occurrence at byte offset
+// pos refers to the entry.
+func (r *EnumEntryRenamer) refersToEntry(fd *fileDecls, visible bool, pos int, name string) bool {
+	i := fd.codeIndex(pos)
+	if i < 0 || fd.code[i].kind != tokIdent {
+		return true // a comment or string the options let through
+	}
+
+	// declarations: the entry itself, or anything else sharing the name
+	for _, c := range fd.classes {
+		if hasInt(c.entries, i) {
+			return c.name == r.ClassName
+		}
+	}
+	if fd.funcAt(i) != nil {
+		return false
+	}
+	for _, p := range fd.props {
+		if p.nameTok == i {
+			return false
+		}
+	}
+	if _, label := fd.namedArgCallee(i); label {
+		return false
+	}
+	if access, _ := fd.receiverEnd(i); access == accessReference || access == accessBareRef {
+		return false
+	}
+
+	// a local, parameter or member property is closer
+	if fd.lookup(name, i) != nil {
+		return false
+	}
+	for c := fd.classAt(i); c != nil; c = c.outer {
+		if c.name == r.ClassName && hasString(c.modifiers, "enum") {
+			return true
+		}
+	}
+	return visible
+}
+
+// warnValueOf reports Status.valueOf("NAME") and enumValueOf<Status>("NAME")
+// calls, whose string argument still names the old entry.
+func (r *EnumEntryRenamer) warnValueOf(fd *fileDecls, name string) {
+	p := &parser{fd: fd, code: fd.code}
+	for k, t := range fd.code {
+		if t.kind != tokString || t.text != `"`+name+`"` || p.text(k-1) != "(" || p.text(k+1) != ")" {
+			continue
+		}
+		switch {
+		case p.text(k-2) == "valueOf" && p.text(k-3) == "." && p.text(k-4) == r.ClassName:
+			r.warn(fd.src, t.start, "%s.valueOf(%s) names the entry in a string — left unchanged", r.ClassName, t.text)
+		case p.text(k-2) == ">" && p.text(k-3) == r.ClassName && p.text(k-4) == "<" && p.text(k-5) == "enumValueOf":
+			r.warn(fd.src, t.start, "enumValueOf<%s>(%s) names the entry in a string — left unchanged", r.ClassName, t.text)
+		}
+	}
+}
+
+func hasInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
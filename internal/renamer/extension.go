@@ -0,0 +1,180 @@
+package renamer
+
+// ExtensionRenamer renames an extension function or extension property
+// declared on Receiver: fun Receiver.name(...) / val Receiver.name.
+//
+// Contexts handled:
+//   - declaration:        fun String.toSlug(  /  val List<Order>.total
+//   - member-style call:  title.toSlug()  /  orders.total
+//   - reference:          String::toSlug
+//   - implicit receiver:  toSlug() inside another String extension or a
+//     member of Receiver
+//   - import:             import com.example.util.toSlug
+//
+// Calls are renamed only when the receiver's type is inferred to be Receiver
+// or one of its subtypes; unresolvable receivers are reported via Warnings.
+// Imports are rewritten when they name a package that declares the
+// extension — every package in the Index, or the file's own without one.
+// An import that also brings in other declarations of the same name from
+// that package is kept, and an import of the new name is added after it.
+type ExtensionRenamer struct {
+	MatchOptions
+	Receiver string // simple name of the receiver type, e.g. "String"
+	Index    *Index // optional: declaring packages and receiver subtypes
+	reporter
+}
+
+func (r *ExtensionRenamer) Rename(content, oldName, newName string) (string, int) {
+	r.reset()
+	fd := parseFile(content)
+	family := r.Index.family(r.Receiver)
+	pkgs, shared := r.packages(fd, oldName)
+
+	out, n := singlePassRename(content, oldName, newName, r.MatchOptions, func(src string, start, end int) bool {
+		if start > 0 && isIdentChar(src[start-1]) || end < len(src) && isIdentChar(src[end]) {
+			return false
+		}
+		return r.refersToExtension(fd, family, pkgs, shared, start, oldName)
+	})
+	if n == 0 {
+		return out, 0
+	}
+	for _, imp := range fd.imports {
+		if pkg, _ := splitFQN(imp.path); shared[pkg] && importsMember(imp, imp.nameStart, oldName, pkgs) && imp.alias == "" {
+			out = addImportAfter(out, content[imp.start:imp.end], pkg+"."+newName)
+		}
+	}
+	return out, n
+}
+
+// packages returns the packages that declare the extension at top level,
+// and among them those that also declare other top-level functions or
+// properties called name.
+func (r *ExtensionRenamer) packages(fd *fileDecls, name string) (pkgs, shared map[string]bool) {
+	files := map[string]*fileDecls{"": fd}
+	if r.Index != nil {
+		files = r.Index.files
+	}
+	pkgs = make(map[string]bool)
+	others := make(map[string]bool)
+	for _, f := range files {
+		if declaresExtension(f, r.Receiver, name) {
+			pkgs[f.pkg] = true
+		}
+		if declaresTopLevel(f, name, func(receiver string) bool { return receiver != r.Receiver }) {
+			others[f.pkg] = true
+		}
+	}
+	shared = make(map[string]bool)
+	for pkg := range pkgs {
+		shared[pkg] = others[pkg]
+	}
+	return pkgs, shared
+}
+
+// declaresExtension reports whether fd declares a top-level extension
+// called name on receiver.
+func declaresExtension(fd *fileDecls, receiver, name string) bool {
+	return declaresTopLevel(fd, name, func(r string) bool { return r == receiver })
+}
+
+// declaresTopLevel reports whether fd declares a top-level function or
+// property called name whose receiver type ("" for none) is accepted by
+// receiver.
+func declaresTopLevel(fd *fileDecls, name string, receiver func(string) bool) bool {
+	for _, f := range fd.funcs {
+		if f.name == name && f.owner == nil && receiver(f.receiver) && fd.scopeAt(f.nameTok) == fd.root {
+			return true
+		}
+	}
+	for _, p := range fd.props {
+		if p.name == name && p.owner == nil && !p.local && receiver(p.receiver) {
+			return true
+		}
+	}
+	return false
+}
+
+// refersToExtension decides whether the occurrence at byte offset pos refers
+// to the extension.  family holds Receiver and its subtypes.
+func (r *ExtensionRenamer) refersToExtension(fd *fileDecls, family, pkgs, shared map[string]bool, pos int, name string) bool {
+	if imp, ok := importAt(fd.imports, pos); ok {
+		if !importsMember(imp, pos, name, pkgs) {
+			return false
+		}
+		if pkg, _ := splitFQN(imp.path); shared[pkg] {
+			if imp.alias != "" {
+				r.warn(fd.src, pos, "import of %s as %s also covers other %s declarations — left unchanged", imp.path, imp.alias, name)
+			}
+			return false // kept for the others; see addImportAfter
+		}
+		return true
+	}
+	i := fd.codeIndex(pos)
+	if i < 0 || fd.code[i].kind != tokIdent {
+		return true
+	}
+
+	// declarations: fun Receiver.name( / val Receiver.name, and other
+	// functions, properties and parameters sharing the name
+	if f := fd.funcAt(i); f != nil {
+		return f.receiver == r.Receiver
+	}
+	for _, p := range fd.props {
+		if p.nameTok == i {
+			return p.receiver == r.Receiver
+		}
+	}
+	if b := fd.lookup(name, i); b != nil && b.tok == i {
+		return false
+	}
+	if _, label := fd.namedArgCallee(i); label {
+		return false
+	}
+
+	access, recv := fd.receiverEnd(i)
+	switch access {
+	case accessDot, accessReference:
+		if _, ok := fd.superTypes(recv); ok {
+			return false
+		}
+		typ, ok := fd.typeOfReceiver(recv)
+		if !ok {
+			r.warn(fd.src, pos, "cannot infer the receiver type of %s — left unchanged", name)
+			return false
+		}
+		return family[typ]
+	case accessBareRef:
+		return false
+	}
+
+	// bare use: a local or parameter of the same name wins, then an
+	// implicit receiver of the right type
+	if b := fd.lookup(name, i); b != nil {
+		return false
+	}
+	for s := fd.scopeAt(i); s != nil; s = s.parent {
+		switch {
+		case s.kind == scopeFunc && s.fn != nil && s.fn.receiver != "":
+			if family[s.fn.receiver] {
+				return true
+			}
+		case s.kind == scopeClass && s.class != nil:
+			if family[s.class.name] {
+				return true
+			}
+			if declaresFunc(s.class, name) {
+				return false
+			}
+		}
+	}
+	if r.Index != nil {
+		for _, f := range r.Index.funcs[name] {
+			if f.receiver == "" {
+				return false // an ordinary function of the same name
+			}
+		}
+	}
+	r.warn(fd.src, pos, "cannot tell whether %s refers to %s.%s — left unchanged", name, r.Receiver, name)
+	return false
+}
@@ -0,0 +1,219 @@
+package renamer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// hardKeywords can never be used as names unless quoted in backticks.
+var hardKeywords = map[string]bool{
+	"as": true, "break": true, "class": true, "continue": true, "do": true, "else": true, "false": true,
+	"for": true, "fun": true, "if": true, "in": true, "interface": true, "is": true, "null": true,
+	"object": true, "package": true, "return": true, "super": true, "this": true, "throw": true,
+	"true": true, "try": true, "typealias": true, "typeof": true, "val": true, "var": true,
+	"when": true, "while": true,
+}
+
+// variance and reification modifiers are keywords where type parameters are
+// declared, so a type parameter can't be named after them.
+var typeParamModifiers = map[string]bool{"out": true, "reified": true}
+
+// ValidateIdentifier checks that s is a valid Kotlin name: a letter or
+// underscore followed by letters, digits and underscores (Unicode ones
+// included), or any name quoted in backticks.  Hard keywords such as fun or
+// when must be quoted; soft and modifier keywords (by, value, data, open...)
+// are ordinary names.
+func ValidateIdentifier(s string) error {
+	if len(s) >= 2 && s[0] == '`' && s[len(s)-1] == '`' {
+		if inner := s[1 : len(s)-1]; inner == "" || strings.ContainsAny(inner, "`\r\n") {
+			return fmt.Errorf("invalid Kotlin identifier: %q", s)
+		}
+		return nil
+	}
+	if !isIdentifier(s) {
+		return fmt.Errorf("invalid Kotlin identifier: %q", s)
+	}
+	if strings.Trim(s, "_") == "" {
+		return fmt.Errorf("invalid Kotlin identifier: %q (names made only of underscores are reserved)", s)
+	}
+	if hardKeywords[s] {
+		return fmt.Errorf("%q is a Kotlin keyword; quote it in backticks (`%s`) to use it as a name", s, s)
+	}
+	return nil
+}
+
+// Unquote strips the backticks from a quoted name: `in` becomes in, the
+// form the index and renamers expect old names in.
+func Unquote(name string) string {
+	return unquoteIdent(name)
+}
+
+// ValidateName checks s as the new name of a symbol of kind, a --type
+// value: besides ValidateIdentifier, it rejects names that are keywords in
+// that position, such as a type parameter called out.
+func ValidateName(kind, s string) error {
+	if err := ValidateIdentifier(s); err != nil {
+		return err
+	}
+	if kind == "type-parameter" && typeParamModifiers[s] {
+		return fmt.Errorf("%q is a modifier where type parameters are declared; quote it in backticks (`%s`) to use it as a name", s, s)
+	}
+	return nil
+}
+
+// ValidatePackageName checks a dotted package name such as
+// com.example.util: every segment must be an identifier and not a hard
+// keyword.
+func ValidatePackageName(pkg string) error {
+	if pkg == "" {
+		return fmt.Errorf("invalid package name: empty")
+	}
+	for _, seg := range strings.Split(pkg, ".") {
+		switch {
+		case !isIdentifier(seg):
+			return fmt.Errorf("invalid package name: %q (expected e.g. com.example.mypackage)", pkg)
+		case hardKeywords[seg]:
+			return fmt.Errorf("invalid package name: %q (%q is a Kotlin keyword)", pkg, seg)
+		}
+	}
+	return nil
+}
+
+// isIdentifier reports whether s is an unquoted Kotlin identifier.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIdentRune reports whether r can be part of an unquoted identifier.
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordAt reports whether src[start:end] is not directly preceded or
+// followed by an identifier character, Unicode letters included.
+func wordAt(src string, start, end int) bool {
+	if r, _ := utf8.DecodeLastRuneInString(src[:start]); start > 0 && isIdentRune(r) {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(src[end:]); end < len(src) && isIdentRune(r) {
+		return false
+	}
+	return true
+}
+
+// ─── naming conventions ───────────────────────────────────────────────────────
+
+// NamingWarning returns a note when name doesn't follow the Kotlin coding
+// conventions for kind — a --type value, "const" for a const val, or
+// "package" — and "" when it does.  Names in backticks, such as test names,
+// are not checked.
+func NamingWarning(kind, name string) string {
+	if strings.HasPrefix(name, "`") {
+		return ""
+	}
+	var ok bool
+	var style, example string
+	switch kind {
+	case "class", "interface", "object", "typealias", "type-parameter":
+		ok, style, example = isPascalCase(name), "PascalCase", pascalCase(name)
+	case "enum-entry":
+		ok, style, example = isScreamingCase(name) || isPascalCase(name), "SCREAMING_SNAKE_CASE", screamingCase(name)
+	case "const":
+		ok, style, example = isScreamingCase(name), "SCREAMING_SNAKE_CASE", screamingCase(name)
+	case "method", "property", "parameter", "extension", "top-level", "local":
+		ok, style, example = isCamelCase(name), "camelCase", camelCase(name)
+	case "package":
+		ok, style, example = name == strings.ToLower(name) && !strings.Contains(name, "_"), "lowercase", strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	default:
+		return ""
+	}
+	if ok {
+		return ""
+	}
+	what := kind
+	if kind == "const" {
+		what = "const val"
+	}
+	return fmt.Sprintf("%s is not %s, the Kotlin convention for %s names (e.g. %s)", name, style, what, example)
+}
+
+func isPascalCase(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsUpper(r) && !strings.Contains(s, "_")
+}
+
+// isCamelCase allows the leading underscore of a private backing property.
+func isCamelCase(s string) bool {
+	s = strings.TrimPrefix(s, "_")
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLower(r) && !strings.Contains(s, "_")
+}
+
+func isScreamingCase(s string) bool {
+	return strings.ToUpper(s) == s && strings.ContainsFunc(s, unicode.IsLetter)
+}
+
+// words splits a name at underscores and lower-to-upper case changes:
+// userAccount_ID → user, Account, ID.
+func words(s string) []string {
+	var out []string
+	var cur []rune
+	prev := rune(0)
+	for _, r := range s {
+		switch {
+		case r == '_':
+			if len(cur) > 0 {
+				out = append(out, string(cur))
+			}
+			cur = nil
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) && len(cur) > 0:
+			out = append(out, string(cur))
+			cur = []rune{r}
+		default:
+			cur = append(cur, r)
+		}
+		prev = r
+	}
+	if len(cur) > 0 {
+		out = append(out, string(cur))
+	}
+	return out
+}
+
+func pascalCase(s string) string {
+	var b strings.Builder
+	for _, w := range words(s) {
+		r, size := utf8.DecodeRuneInString(w)
+		b.WriteRune(unicode.ToUpper(r))
+		rest := w[size:]
+		if strings.ToUpper(w) == w {
+			rest = strings.ToLower(rest) // MAX_RETRIES → MaxRetries
+		}
+		b.WriteString(rest)
+	}
+	return b.String()
+}
+
+func camelCase(s string) string {
+	p := pascalCase(s)
+	r, size := utf8.DecodeRuneInString(p)
+	return string(unicode.ToLower(r)) + p[size:]
+}
+
+func screamingCase(s string) string {
+	ws := words(s)
+	for i, w := range ws {
+		ws[i] = strings.ToUpper(w)
+	}
+	return strings.Join(ws, "_")
+}
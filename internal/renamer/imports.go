@@ -0,0 +1,269 @@
+package renamer
+
+import (
+	"strings"
+)
+
+// kotlinImport is a single import directive.
+//
+//	import com.example.User          → path "com.example.User"
+//	import com.example.User as U     → path "com.example.User", alias "U"
+//	import com.example.*             → path "com.example", wildcard
+type kotlinImport struct {
+	path     string
+	alias    string
+	wildcard bool
+	// start/end span the directive from "import" to the end of its path or
+	// alias; nameStart and pathEnd span the path's last identifier.
+	start     int
+	end       int
+	nameStart int
+	pathEnd   int
+}
+
+// name returns the simple name the import introduces into the file: the
+// alias if present, otherwise the last path segment.  Wildcards introduce no
+// single name.
+func (imp kotlinImport) name() string {
+	if imp.wildcard {
+		return ""
+	}
+	if imp.alias != "" {
+		return imp.alias
+	}
+	_, simple := splitFQN(imp.path)
+	return simple
+}
+
+// parseImports returns the import directives of a Kotlin file in source
+// order.  Comments between or inside directives are ignored.
+func parseImports(src string) []kotlinImport {
+	code := codeTokens(tokenize(src))
+
+	var imports []kotlinImport
+	for i := 0; i < len(code); i++ {
+		t := code[i]
+		if t.kind != tokIdent || t.text != "import" || !startsLine(src, t.start) {
+			continue
+		}
+
+		imp := kotlinImport{start: t.start}
+		var parts []string
+		j := i + 1
+		for j < len(code) && code[j].kind == tokIdent {
+			parts = append(parts, unquoteIdent(code[j].text))
+			imp.end = code[j].end
+			imp.nameStart, imp.pathEnd = code[j].start, code[j].end
+			j++
+			if j+1 < len(code) && code[j].text == "." && code[j+1].kind == tokIdent {
+				j++
+				continue
+			}
+			if j+1 < len(code) && code[j].text == "." && code[j+1].text == "*" {
+				imp.wildcard = true
+				imp.end = code[j+1].end
+				j += 2
+			}
+			break
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		imp.path = strings.Join(parts, ".")
+
+		if !imp.wildcard && j+1 < len(code) && code[j].text == "as" && code[j+1].kind == tokIdent {
+			imp.alias = unquoteIdent(code[j+1].text)
+			imp.end = code[j+1].end
+			j += 2
+		}
+
+		imports = append(imports, imp)
+		i = j - 1
+	}
+	return imports
+}
+
+// importAt returns the import directive spanning byte offset pos.
+func importAt(imports []kotlinImport, pos int) (kotlinImport, bool) {
+	for _, imp := range imports {
+		if imp.start <= pos && pos < imp.end {
+			return imp, true
+		}
+	}
+	return kotlinImport{}, false
+}
+
+// importsMember reports whether the identifier at pos is the last path
+// segment of imp and imp imports name from one of pkgs.
+func importsMember(imp kotlinImport, pos int, name string, pkgs map[string]bool) bool {
+	pkg, last := splitFQN(imp.path)
+	return !imp.wildcard && last == name && pkgs[pkg] && pos == imp.nameStart
+}
+
+// addImportAfter adds "import path" on a new line after the first occurrence
+// of the import directive text in src, unless src already imports path.
+func addImportAfter(src, directive, path string) string {
+	for _, imp := range parseImports(src) {
+		if imp.path == path && imp.alias == "" {
+			return src
+		}
+	}
+	i := strings.Index(src, directive)
+	if i < 0 {
+		return src
+	}
+	i += len(directive)
+	return src[:i] + "\nimport " + path + src[i:]
+}
+
+// addImport adds "import path" on a new line after the last import
+// directive of src — or after its package declaration when it has none —
+// unless src already imports path.  Reports whether it was added.
+func addImport(src, path string) (string, bool) {
+	imports := parseImports(src)
+	for _, imp := range imports {
+		if !imp.wildcard && imp.path == path && imp.alias == "" {
+			return src, false
+		}
+	}
+	if len(imports) > 0 {
+		i := imports[len(imports)-1].end
+		return src[:i] + "\nimport " + path + src[i:], true
+	}
+	if loc := packageDeclPat.FindStringIndex(src); loc != nil {
+		i := loc[1]
+		return src[:i] + "\n\nimport " + path + src[i:], true
+	}
+	return "import " + path + "\n\n" + src, true
+}
+
+// removeDirective deletes the directive at src[start:end] together with its
+// line, when nothing else is on it, and with a blank line that would
+// otherwise double the one before it.
+func removeDirective(src string, start, end int) string {
+	lo := strings.LastIndexByte(src[:start], '\n') + 1
+	hi := len(src)
+	if k := strings.IndexByte(src[end:], '\n'); k >= 0 {
+		hi = end + k + 1
+	}
+	if strings.TrimSpace(src[lo:start]) != "" || strings.TrimSpace(src[end:hi]) != "" {
+		return src[:start] + src[end:]
+	}
+	if strings.HasSuffix(src[:lo], "\n\n") && strings.HasPrefix(src[hi:], "\n") {
+		hi++
+	}
+	return src[:lo] + src[hi:]
+}
+
+// resolvesToClass reports whether the unqualified simple name of the
+// classifier fqn, written in src, refers to that classifier.  Kotlin resolves
+// simple names in this order, and so do we:
+//
+//  1. explicit imports (an aliased import hides the original name)
+//  2. declarations in the file's own package, including the file itself
+//  3. wildcard imports
+func resolvesToClass(src string, imports []kotlinImport, fqn string) bool {
+	pkg, name := splitFQN(fqn)
+
+	for _, imp := range imports {
+		if imp.wildcard {
+			continue
+		}
+		if imp.name() == name {
+			return imp.path == fqn
+		}
+	}
+
+	if extractPackage(src) == pkg {
+		return true
+	}
+	if declaresClassifier(src, name) {
+		return false
+	}
+
+	for _, imp := range imports {
+		if imp.wildcard && imp.path == pkg {
+			return true
+		}
+	}
+	return false
+}
+
+// declaresClassifier reports whether src declares a class, interface,
+// object or typealias called name.
+func declaresClassifier(src, name string) bool {
+	code := codeTokens(tokenize(src))
+	for i := 0; i+1 < len(code); i++ {
+		switch code[i].text {
+		case "class", "interface", "object", "typealias":
+			if i > 0 && code[i-1].text == ":" && i > 1 && code[i-2].text == ":" {
+				continue // Foo::class literal
+			}
+			if code[i+1].kind == tokIdent && unquoteIdent(code[i+1].text) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// qualifierBefore returns the dotted qualifier written immediately before
+// src[start:], e.g. "com.example" for the User in "com.example.User".  ok is
+// false when the identifier is not preceded by a "." at all.
+func qualifierBefore(src string, start int) (qualifier string, ok bool) {
+	from, end, ok := qualifierSpan(src, start)
+	if !ok {
+		return "", false
+	}
+	return strings.ReplaceAll(src[from:end], "`", ""), true
+}
+
+// qualifierSpan returns the byte range of the qualifier qualifierBefore
+// reads, without the dot that follows it.
+func qualifierSpan(src string, start int) (from, end int, ok bool) {
+	i := start
+	for i > 0 && (src[i-1] == ' ' || src[i-1] == '\t') {
+		i--
+	}
+	if i == 0 || src[i-1] != '.' {
+		return 0, 0, false
+	}
+	i--
+
+	end = i
+	for i > 0 && (isIdentChar(src[i-1]) || src[i-1] == '.' || src[i-1] == '`') {
+		i--
+	}
+	return i, end, true
+}
+
+// splitFQN splits "com.example.User" into "com.example" and "User".
+func splitFQN(fqn string) (pkg, name string) {
+	if i := strings.LastIndex(fqn, "."); i >= 0 {
+		return fqn[:i], fqn[i+1:]
+	}
+	return "", fqn
+}
+
+// startsLine reports whether only spaces and tabs precede pos on its line.
+func startsLine(src string, pos int) bool {
+	for i := pos - 1; i >= 0; i-- {
+		switch src[i] {
+		case ' ', '\t':
+			continue
+		case '\n':
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unquoteIdent strips the backticks from a `quoted` identifier.
+func unquoteIdent(s string) string {
+	if len(s) >= 2 && s[0] == '`' && s[len(s)-1] == '`' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,347 @@
+package renamer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Index is a project-wide table of the Kotlin declarations in a set of
+// files.  It is built once before a rename so that per-file passes can
+// resolve inheritance and find the declaration a symbol belongs to.
+type Index struct {
+	files   map[string]*fileDecls   // by path
+	classes map[string][]*classDecl // by simple name
+	funcs   map[string][]*funcDecl  // by name, members and locals included
+}
+
+// BuildIndex parses every file in paths.
+func BuildIndex(paths []string) (*Index, error) {
+	ix := newIndex()
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("indexing %s: %w", path, err)
+		}
+		ix.add(path, string(raw))
+	}
+	return ix, nil
+}
+
+func newIndex() *Index {
+	return &Index{
+		files:   make(map[string]*fileDecls),
+		classes: make(map[string][]*classDecl),
+		funcs:   make(map[string][]*funcDecl),
+	}
+}
+
+func (ix *Index) add(path, src string) {
+	fd := parseFile(src)
+	fd.path = path
+	ix.files[path] = fd
+	for _, c := range fd.classes {
+		if c.name != "" {
+			ix.classes[c.name] = append(ix.classes[c.name], c)
+		}
+	}
+	for _, f := range fd.funcs {
+		ix.funcs[f.name] = append(ix.funcs[f.name], f)
+	}
+}
+
+// HasClass reports whether a class, interface or object called name is
+// declared in the indexed files.
+func (ix *Index) HasClass(name string) bool {
+	return len(ix.classes[name]) > 0
+}
+
+// Subtypes returns the names of all classes that directly or transitively
+// extend or implement the class called name, sorted.
+func (ix *Index) Subtypes(name string) []string {
+	var out []string
+	for sub := range ix.family(name) {
+		if sub != name {
+			out = append(out, sub)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// family returns name together with all of its subtypes.
+func (ix *Index) family(name string) map[string]bool {
+	fam := map[string]bool{name: true}
+	if ix == nil {
+		return fam
+	}
+	for changed := true; changed; {
+		changed = false
+		for sub, decls := range ix.classes {
+			if fam[sub] {
+				continue
+			}
+			for _, c := range decls {
+				for _, st := range c.supertypes {
+					if fam[st] {
+						fam[sub] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	return fam
+}
+
+// PropertyOwners returns the names of the classes that declare a member
+// property called name (overrides excluded), sorted.
+func (ix *Index) PropertyOwners(name string) []string {
+	seen := make(map[string]bool)
+	for _, fd := range ix.files {
+		for _, p := range fd.props {
+			if p.name == name && p.owner != nil && p.owner.name != "" && !p.local &&
+				p.receiver == "" && !hasString(p.modifiers, "override") {
+				seen[p.owner.name] = true
+			}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// Overloads returns the signatures of the functions called name declared in
+// className, or anywhere when className is "", sorted and without
+// duplicates.
+func (ix *Index) Overloads(className, name string) []Signature {
+	seen := make(map[string]bool)
+	var out []Signature
+	for _, f := range ix.funcs[name] {
+		if className != "" && (f.owner == nil || f.owner.name != className) {
+			continue
+		}
+		sig := signatureOf(f)
+		if !seen[sig.String()] {
+			seen[sig.String()] = true
+			out = append(out, sig)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+	return out
+}
+
+// ExtensionReceivers returns the receiver types of the extension functions
+// and properties called name, sorted.
+func (ix *Index) ExtensionReceivers(name string) []string {
+	seen := make(map[string]bool)
+	for _, fd := range ix.files {
+		for _, f := range fd.funcs {
+			if f.name == name && f.receiver != "" {
+				seen[f.receiver] = true
+			}
+		}
+		for _, p := range fd.props {
+			if p.name == name && p.receiver != "" {
+				seen[p.receiver] = true
+			}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// TopLevelDeclarations returns the fully-qualified names of the top-level
+// functions and properties called name (extensions excluded), sorted.
+func (ix *Index) TopLevelDeclarations(name string) []string {
+	seen := make(map[string]bool)
+	for _, fd := range ix.files {
+		if declaresTopLevel(fd, name, func(receiver string) bool { return receiver == "" }) {
+			fqn := name
+			if fd.pkg != "" {
+				fqn = fd.pkg + "." + name
+			}
+			seen[fqn] = true
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// TypeAliases returns the fully-qualified names of the typealiases called
+// name, sorted.
+func (ix *Index) TypeAliases(name string) []string {
+	seen := make(map[string]bool)
+	for _, fd := range ix.files {
+		for _, a := range fd.aliases {
+			if a.name != name {
+				continue
+			}
+			fqn := name
+			if fd.pkg != "" {
+				fqn = fd.pkg + "." + name
+			}
+			seen[fqn] = true
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// IsConstant reports whether a const val called name is declared, as a
+// member of className when given, or at top level.
+func (ix *Index) IsConstant(className, name string) bool {
+	for _, fd := range ix.files {
+		for _, p := range fd.props {
+			if p.name != name || !hasString(p.modifiers, "const") {
+				continue
+			}
+			if className == "" && p.owner == nil || p.owner != nil && p.owner.name == className {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EnumEntries returns the entry names of the enum classes called className,
+// sorted, and whether any such enum class was found.
+func (ix *Index) EnumEntries(className string) ([]string, bool) {
+	seen := make(map[string]bool)
+	found := false
+	for _, fd := range ix.files {
+		for _, c := range fd.classes {
+			if c.name != className || !hasString(c.modifiers, "enum") {
+				continue
+			}
+			found = true
+			for _, e := range c.entries {
+				seen[unquoteIdent(fd.code[e].text)] = true
+			}
+		}
+	}
+	return sortedKeys(seen), found
+}
+
+// OverrideFamily is the set of classes sharing one overridable member: the
+// root declaration, every override of it, and every class inheriting it.
+type OverrideFamily struct {
+	// Roots are the classes declaring the member without "override",
+	// sorted.  There is more than one only when a class inherits the same
+	// member from several supertypes.
+	Roots []string
+	// Declared are the classes that declare or override the member, sorted.
+	Declared []string
+	// Classes are Declared plus every subtype inheriting the member, sorted.
+	// A receiver of any of these types refers to the member.
+	Classes []string
+}
+
+// MemberFamily resolves the member called name (a property when property is
+// true, otherwise a function) as seen from className, walking up to the
+// declaration it overrides and back down to every override.  It fails when
+// the member is not found or overrides a declaration outside the indexed
+// files.
+func (ix *Index) MemberFamily(className, name string, property bool) (*OverrideFamily, error) {
+	if len(ix.classes[className]) == 0 {
+		return nil, fmt.Errorf("class %s not found", className)
+	}
+
+	roots := make(map[string]bool)
+	var climb func(cls string, seen map[string]bool)
+	climb = func(cls string, seen map[string]bool) {
+		if seen[cls] {
+			return
+		}
+		seen[cls] = true
+		declared, override := ix.declaresMember(cls, name, property)
+		if declared && !override {
+			roots[cls] = true
+			return
+		}
+		for _, c := range ix.classes[cls] {
+			for _, st := range c.supertypes {
+				climb(st, seen)
+			}
+		}
+	}
+	climb(className, make(map[string]bool))
+
+	if len(roots) == 0 {
+		if declared, _ := ix.declaresMember(className, name, property); declared {
+			return nil, fmt.Errorf("%s.%s overrides a declaration outside the project; rename it there first", className, name)
+		}
+		return nil, fmt.Errorf("%s has no member named %s", className, name)
+	}
+
+	// Grow the family downwards from the roots.  An override may also
+	// implement the member of another supertype, whose root then joins.
+	classes := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for root := range roots {
+			for cls := range ix.family(root) {
+				if !classes[cls] {
+					classes[cls] = true
+					changed = true
+				}
+			}
+		}
+		for cls := range classes {
+			if _, override := ix.declaresMember(cls, name, property); override {
+				before := len(roots)
+				climb(cls, make(map[string]bool))
+				changed = changed || len(roots) != before
+			}
+		}
+	}
+
+	fam := &OverrideFamily{Roots: sortedKeys(roots), Classes: sortedKeys(classes)}
+	for _, cls := range fam.Classes {
+		if declared, _ := ix.declaresMember(cls, name, property); declared {
+			fam.Declared = append(fam.Declared, cls)
+		}
+	}
+	return fam, nil
+}
+
+// declaresMember reports whether a class called cls declares the member, and
+// whether that declaration is an override.
+func (ix *Index) declaresMember(cls, name string, property bool) (declared, override bool) {
+	for _, c := range ix.classes[cls] {
+		if property {
+			for _, p := range c.props {
+				if p.name == name && p.receiver == "" {
+					return true, hasString(p.modifiers, "override")
+				}
+			}
+			continue
+		}
+		for _, f := range c.funcs {
+			if f.name == name && f.receiver == "" {
+				return true, hasString(f.modifiers, "override")
+			}
+		}
+	}
+	return false, false
+}
+
+// memberClasses returns the classes whose receivers refer to the member
+// declared in className: its whole override family when ix knows about it,
+// otherwise className and whatever subtypes ix knows.
+func (ix *Index) memberClasses(className, name string, property bool) map[string]bool {
+	if ix != nil {
+		if fam, err := ix.MemberFamily(className, name, property); err == nil {
+			set := make(map[string]bool, len(fam.Classes))
+			for _, c := range fam.Classes {
+				set[c] = true
+			}
+			return set
+		}
+	}
+	return ix.family(className)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,256 @@
+package renamer
+
+import (
+	"strings"
+)
+
+// ─── type inference ───────────────────────────────────────────────────────────
+//
+// kr does not type-check Kotlin.  These helpers recover the static type of an
+// expression only in the cases that are obvious from the source text:
+//
+//	val cart: CartService            declared type
+//	val cart = CartService(...)      constructor call
+//	this / this@CartService          enclosing class
+//	CartService.create()             companion / object access
+//	"text".toSlug()                  literals
+//
+// Anything else is reported as unknown, and callers decide whether to skip
+// the occurrence silently or warn about it.
+
+// inferExprType returns the simple type name of the expression starting at
+// code[i], or "" if it is not obvious.
+func (p *parser) inferExprType(i, to int) string {
+	if i >= to {
+		return ""
+	}
+	switch t := p.code[i]; t.kind {
+	case tokString:
+		return "String"
+	case tokChar:
+		return "Char"
+	case tokNumber:
+		return numberType(t.text)
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return "Boolean"
+		}
+		// Type(...) or Type<Args>(...)
+		j := i + 1
+		if p.text(j) == "<" {
+			j = p.skipAngles(j)
+		}
+		if p.text(j) == "(" && isTypeName(t.text) {
+			end := matchingToken(p.code, j)
+			if end >= 0 && !continuesExpr(p.text(end+1)) {
+				return unquoteIdent(t.text)
+			}
+		}
+	}
+	return ""
+}
+
+// continuesExpr reports whether the token after a call keeps the expression
+// going, in which case the call's type is not the expression's type.
+func continuesExpr(next string) bool {
+	switch next {
+	case ".", "?", "!", "+", "-", "*", "/", "%", "[", "(":
+		return true
+	}
+	return next == "as"
+}
+
+// numberType returns the type of a numeric literal.
+func numberType(lit string) string {
+	l := strings.ToLower(lit)
+	switch {
+	case strings.HasPrefix(l, "0x") || strings.HasPrefix(l, "0b"):
+		if strings.HasSuffix(l, "l") {
+			return "Long"
+		}
+		return "Int"
+	case strings.HasSuffix(l, "l"):
+		return "Long"
+	case strings.HasSuffix(l, "f"):
+		return "Float"
+	case strings.ContainsAny(l, ".e"):
+		return "Double"
+	case strings.HasSuffix(l, "u"), strings.HasSuffix(l, "ul"):
+		return "UInt"
+	}
+	return "Int"
+}
+
+// isTypeName reports whether an identifier looks like a type (Kotlin style:
+// starts with an upper-case letter).
+func isTypeName(s string) bool {
+	s = unquoteIdent(s)
+	return s != "" && s[0] >= 'A' && s[0] <= 'Z'
+}
+
+// memberAccess classifies how the identifier at code[i] is reached.
+type memberAccess int
+
+const (
+	accessBare      memberAccess = iota // name
+	accessDot                           // recv.name / recv?.name / recv!!.name
+	accessReference                     // recv::name
+	accessBareRef                       // ::name
+)
+
+// receiverEnd returns how code[i] is accessed and, for accessDot and
+// accessReference, the index of the last token of the receiver expression.
+func (fd *fileDecls) receiverEnd(i int) (memberAccess, int) {
+	text := func(k int) string {
+		if k < 0 || k >= len(fd.code) {
+			return ""
+		}
+		return fd.code[k].text
+	}
+
+	switch {
+	case text(i-1) == ":" && text(i-2) == ":":
+		r := i - 3
+		if r < 0 || (fd.code[r].kind != tokIdent && text(r) != ")" && text(r) != ">") {
+			return accessBareRef, -1
+		}
+		return accessReference, r
+	case text(i-1) == ".":
+		r := i - 2
+		if text(r) == "?" {
+			r--
+		}
+		for text(r) == "!" {
+			r--
+		}
+		return accessDot, r
+	}
+	return accessBare, -1
+}
+
+// typeOfReceiver infers the type of the receiver expression ending at
+// code[r] (see receiverEnd).  ok is false when it cannot be determined.
+func (fd *fileDecls) typeOfReceiver(r int) (typ string, ok bool) {
+	if r < 0 || r >= len(fd.code) {
+		return "", false
+	}
+	t := fd.code[r]
+	text := func(k int) string {
+		if k < 0 || k >= len(fd.code) {
+			return ""
+		}
+		return fd.code[k].text
+	}
+
+	switch t.kind {
+	case tokString:
+		return "String", true
+	case tokChar:
+		return "Char", true
+	case tokNumber:
+		return numberType(t.text), true
+	case tokIdent:
+	default:
+		switch t.text {
+		case ")":
+			// constructor call: Type(...).member
+			o := matchingOpen(fd.code, r)
+			if o > 0 && fd.code[o-1].kind == tokIdent && isTypeName(text(o-1)) && text(o-2) != "." {
+				return unquoteIdent(text(o - 1)), true
+			}
+		}
+		return "", false
+	}
+
+	// this@Label
+	if text(r-1) == "@" && text(r-2) == "this" {
+		return unquoteIdent(t.text), true
+	}
+
+	name := unquoteIdent(t.text)
+	switch {
+	case name == "this":
+		typ := fd.thisType(r)
+		return typ, typ != ""
+	case name == "super":
+		return "", false // see superTypes
+	case text(r-1) == "." || text(r-1) == ":":
+		// a.b.member — the type of b depends on the type of a
+		if isTypeName(name) {
+			return name, true // com.example.Type.member
+		}
+		return "", false
+	}
+
+	if b := fd.lookup(name, r); b != nil {
+		return b.typ, b.typ != ""
+	}
+	if isTypeName(name) {
+		return name, true // Object.member or Type.Companion.member
+	}
+	return "", false
+}
+
+// namedArgCallee reports whether code[i] is the label of a named argument,
+// name = value, and if so returns the index of the last token of the callee
+// expression before the argument list's "(".
+func (fd *fileDecls) namedArgCallee(i int) (int, bool) {
+	text := func(k int) string {
+		if k < 0 || k >= len(fd.code) {
+			return ""
+		}
+		return fd.code[k].text
+	}
+	if text(i+1) != "=" || text(i+2) == "=" {
+		return -1, false
+	}
+	if prev := text(i - 1); prev != "(" && prev != "," {
+		return -1, false
+	}
+
+	depth := 0
+	for k := i - 1; k >= 0; k-- {
+		switch text(k) {
+		case ")", "]", "}":
+			depth++
+		case "[", "{":
+			if depth == 0 {
+				return -1, false
+			}
+			depth--
+		case "(":
+			if depth == 0 {
+				return k - 1, k > 0
+			}
+			depth--
+		}
+	}
+	return -1, false
+}
+
+// superTypes returns the types a super receiver ending at code[r] may refer
+// to: the one named in super<Base>, or every supertype of the enclosing
+// class for a plain super.  ok is false if code[r] is not a super receiver.
+func (fd *fileDecls) superTypes(r int) ([]string, bool) {
+	if r < 0 || r >= len(fd.code) {
+		return nil, false
+	}
+	if fd.code[r].text == ">" {
+		open := r
+		for open > 0 && fd.code[open].text != "<" {
+			open--
+		}
+		if open > 0 && fd.code[open-1].text == "super" {
+			return []string{simpleTypeName(fd.src[fd.code[open+1].start:fd.code[r-1].end])}, true
+		}
+		return nil, false
+	}
+	if fd.code[r].text != "super" {
+		return nil, false
+	}
+	if c := fd.classAt(r); c != nil {
+		return c.supertypes, true
+	}
+	return nil, true
+}
@@ -0,0 +1,350 @@
+package renamer
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind classifies a lexical token in Kotlin source.
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokNumber
+	tokPunct
+	tokString
+	tokChar
+	tokLineComment
+	tokBlockComment
+)
+
+// token is a single lexical unit.  Start/End are byte offsets into the source
+// the token was produced from; text is src[Start:End].
+type token struct {
+	kind  tokenKind
+	start int
+	end   int
+	text  string
+}
+
+// isCode reports whether t is program text rather than a comment or literal.
+func (t token) isCode() bool {
+	switch t.kind {
+	case tokString, tokChar, tokLineComment, tokBlockComment:
+		return false
+	}
+	return true
+}
+
+func (t token) isComment() bool {
+	return t.kind == tokLineComment || t.kind == tokBlockComment
+}
+
+// MatchOptions controls which lexical regions a renamer may rewrite.  By
+// default only program text is touched; comments and string/char literals
+// are left exactly as written.
+type MatchOptions struct {
+	// IncludeComments also rewrites matches inside // and /* */ comments.
+	IncludeComments bool
+	// IncludeStrings also rewrites matches inside string and char literals.
+	IncludeStrings bool
+}
+
+// allows reports whether a match inside a token of kind k may be rewritten.
+func (o MatchOptions) allows(k tokenKind) bool {
+	switch k {
+	case tokLineComment, tokBlockComment:
+		return o.IncludeComments
+	case tokString, tokChar:
+		return o.IncludeStrings
+	}
+	return true
+}
+
+// ─── tokenizer ────────────────────────────────────────────────────────────────
+
+// tokenize splits Kotlin source into tokens.  Whitespace is dropped; every
+// other byte of src belongs to exactly one token, and tokens are returned in
+// source order.
+//
+// The lexer understands:
+//   - line comments and (nested) block comments
+//   - "..." strings with escapes, """raw""" strings, and '...' char literals
+//   - $name and ${...} templates, whose contents are emitted as code tokens
+//     between string pieces; strings and braces nested inside ${...} are
+//     balanced, so they never terminate the surrounding literal
+//   - `backtick-quoted` identifiers
+func tokenize(src string) []token {
+	lx := &lexer{src: src}
+	lx.run(false)
+	return lx.toks
+}
+
+type lexer struct {
+	src  string
+	pos  int
+	toks []token
+}
+
+func (lx *lexer) emit(kind tokenKind, start int) {
+	lx.toks = append(lx.toks, token{kind: kind, start: start, end: lx.pos, text: lx.src[start:lx.pos]})
+}
+
+// run lexes until end of input or, when inTemplate is true, until the '}'
+// closing a ${...} template expression.  It returns true if it stopped on
+// that closing brace, which is consumed but left for the caller to emit.
+func (lx *lexer) run(inTemplate bool) bool {
+	depth := 0
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		start := lx.pos
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			lx.pos++
+
+		case strings.HasPrefix(lx.src[lx.pos:], "//"):
+			end := strings.IndexByte(lx.src[lx.pos:], '\n')
+			if end < 0 {
+				lx.pos = len(lx.src)
+			} else {
+				lx.pos += end
+			}
+			lx.emit(tokLineComment, start)
+
+		case strings.HasPrefix(lx.src[lx.pos:], "/*"):
+			lx.blockComment()
+			lx.emit(tokBlockComment, start)
+
+		case strings.HasPrefix(lx.src[lx.pos:], `"""`):
+			lx.stringLiteral(true)
+
+		case c == '"':
+			lx.stringLiteral(false)
+
+		case c == '\'':
+			lx.charLiteral()
+			lx.emit(tokChar, start)
+
+		case c == '`':
+			end := strings.IndexAny(lx.src[lx.pos+1:], "`\n")
+			if end < 0 || lx.src[lx.pos+1+end] != '`' {
+				// Unterminated — treat the backtick as punctuation.
+				lx.pos++
+				lx.emit(tokPunct, start)
+				continue
+			}
+			lx.pos += end + 2
+			lx.emit(tokIdent, start)
+
+		case c >= '0' && c <= '9':
+			lx.number(start)
+			lx.emit(tokNumber, start)
+
+		case isIdentStart(lx.src[lx.pos:]):
+			lx.ident()
+			lx.emit(tokIdent, start)
+
+		default:
+			if inTemplate {
+				if c == '{' {
+					depth++
+				} else if c == '}' {
+					if depth == 0 {
+						lx.pos++
+						return true
+					}
+					depth--
+				}
+			}
+			_, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+			lx.pos += size
+			lx.emit(tokPunct, start)
+		}
+	}
+	return false
+}
+
+func (lx *lexer) blockComment() {
+	depth := 0
+	for lx.pos < len(lx.src) {
+		switch {
+		case strings.HasPrefix(lx.src[lx.pos:], "/*"):
+			depth++
+			lx.pos += 2
+		case strings.HasPrefix(lx.src[lx.pos:], "*/"):
+			depth--
+			lx.pos += 2
+			if depth == 0 {
+				return
+			}
+		default:
+			lx.pos++
+		}
+	}
+}
+
+// stringLiteral consumes a "..." or """...""" literal starting at lx.pos.
+// Literal text is emitted as tokString pieces and template expressions are
+// lexed as ordinary code between them:
+//
+//	"Hi ${user.name}!"  →  `"Hi ${`  user  .  name  `}!"`
+//	"id=$userId"        →  `"id=$`  userId  `"`
+//
+// The piece before a template keeps its "$" / "${" and the piece after a
+// ${...} template starts with its "}", so pieces still tile the source.
+func (lx *lexer) stringLiteral(raw bool) {
+	start := lx.pos
+	if raw {
+		lx.pos += 3
+	} else {
+		lx.pos++
+	}
+
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch {
+		case raw && strings.HasPrefix(lx.src[lx.pos:], `"""`):
+			// A run of more than three closing quotes ends the literal at
+			// its last three quotes, as in Kotlin.
+			lx.pos += 3
+			for lx.pos < len(lx.src) && lx.src[lx.pos] == '"' {
+				lx.pos++
+			}
+			lx.emit(tokString, start)
+			return
+		case !raw && c == '"':
+			lx.pos++
+			lx.emit(tokString, start)
+			return
+		case !raw && c == '\\':
+			lx.pos += 2
+		case !raw && c == '\n':
+			// An unclosed literal ends at the line break so one typo cannot
+			// swallow the rest of the file.
+			lx.emit(tokString, start)
+			return
+		case strings.HasPrefix(lx.src[lx.pos:], "${"):
+			lx.pos += 2
+			lx.emit(tokString, start)
+			if !lx.run(true) {
+				return
+			}
+			// The closing brace opens the next literal piece.
+			start = lx.pos - 1
+		case c == '$' && lx.pos+1 < len(lx.src) && isIdentStart(lx.src[lx.pos+1:]):
+			lx.pos++
+			lx.emit(tokString, start)
+			start = lx.pos
+			lx.ident()
+			lx.emit(tokIdent, start)
+			start = lx.pos
+		default:
+			lx.pos++
+		}
+	}
+	if lx.pos > len(lx.src) {
+		lx.pos = len(lx.src)
+	}
+	if lx.pos > start {
+		lx.emit(tokString, start)
+	}
+}
+
+func (lx *lexer) charLiteral() {
+	lx.pos++
+	for lx.pos < len(lx.src) {
+		switch lx.src[lx.pos] {
+		case '\\':
+			lx.pos += 2
+		case '\'':
+			lx.pos++
+			return
+		case '\n':
+			return
+		default:
+			lx.pos++
+		}
+	}
+	if lx.pos > len(lx.src) {
+		lx.pos = len(lx.src)
+	}
+}
+
+func (lx *lexer) number(start int) {
+	hex := strings.HasPrefix(lx.src[start:], "0x") || strings.HasPrefix(lx.src[start:], "0X")
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch {
+		case isIdentChar(c):
+			lx.pos++
+		case c == '.' && lx.pos+1 < len(lx.src) && lx.src[lx.pos+1] >= '0' && lx.src[lx.pos+1] <= '9':
+			lx.pos++
+		case (c == '+' || c == '-') && !hex && (lx.src[lx.pos-1] == 'e' || lx.src[lx.pos-1] == 'E'):
+			lx.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (lx *lexer) ident() {
+	for lx.pos < len(lx.src) {
+		r, size := utf8.DecodeRuneInString(lx.src[lx.pos:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return
+		}
+		lx.pos += size
+	}
+}
+
+func isIdentStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// ─── lookup helpers ───────────────────────────────────────────────────────────
+
+// tokenAt returns the index of the token containing byte offset pos, or -1 if
+// pos falls in whitespace.
+func tokenAt(toks []token, pos int) int {
+	i := sort.Search(len(toks), func(i int) bool { return toks[i].end > pos })
+	if i < len(toks) && toks[i].start <= pos {
+		return i
+	}
+	return -1
+}
+
+// isShortTemplate reports whether toks[i] is the identifier of a $name
+// template.  What follows such an identifier is literal text, so "(" after
+// "$fetch" is not a call.
+func isShortTemplate(toks []token, i int) bool {
+	if i <= 0 || toks[i].kind != tokIdent {
+		return false
+	}
+	prev := toks[i-1]
+	return prev.kind == tokString && prev.end == toks[i].start && strings.HasSuffix(prev.text, "$")
+}
+
+// kindAt returns the kind of the token containing pos.  Whitespace counts as
+// code.
+func kindAt(toks []token, pos int) tokenKind {
+	if i := tokenAt(toks, pos); i >= 0 {
+		return toks[i].kind
+	}
+	return tokPunct
+}
+
+// codeTokens returns toks with comments removed.  String and char literals
+// are kept so that parsers still see them as opaque operands.
+func codeTokens(toks []token) []token {
+	out := make([]token, 0, len(toks))
+	for _, t := range toks {
+		if !t.isComment() {
+			out = append(out, t)
+		}
+	}
+	return out
+}
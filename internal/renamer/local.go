@@ -0,0 +1,228 @@
+package renamer
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// LocalRenamer renames local variables: a val/var declared in a function
+// body, initializer block or lambda, a name in a destructuring declaration
+// val (a, b) = pair, a for-loop variable, or a lambda parameter
+// { user -> ... } / { (k, v) -> ... }.
+//
+// Locals are selected by Function (declared anywhere inside a function of
+// that name) and/or Line (declared, or referenced, on that line).  Only
+// references that resolve to a selected declaration are renamed: a nested
+// block's own local of the same name shadows it, an initializer such as
+// val total = total + 1 still sees the outer one, and member accesses
+// (order.total) and named arguments (total = ...) are left alone.
+//
+// Renaming it converts the implicit parameter of the selected lambdas into
+// a named one: users.map { it.name } becomes users.map { user -> user.name }.
+// An it inside a nested lambda that has its own it is left alone.
+type LocalRenamer struct {
+	MatchOptions
+	Function string // optional: only locals declared inside functions with this name
+	Line     int    // optional: only the local declared or referenced on this 1-based line
+	Column   int    // optional, with Line: only the identifier or lambda "{" at this 1-based column
+}
+
+func (r *LocalRenamer) Rename(content, oldName, newName string) (string, int) {
+	fd := parseFile(content)
+	decls := r.declarations(fd, oldName)
+	var lambdas map[*scope]bool
+	if oldName == "it" {
+		lambdas = r.itLambdas(fd)
+	}
+	if len(decls) == 0 && len(lambdas) == 0 {
+		return content, 0
+	}
+
+	starts := findMatches(content, fd.toks, oldName, r.MatchOptions, 0, len(content), func(src string, start, end int) bool {
+		if start > 0 && isIdentChar(src[start-1]) || end < len(src) && isIdentChar(src[end]) {
+			return false
+		}
+		i := fd.codeIndex(start)
+		if i < 0 || fd.code[i].kind != tokIdent {
+			return false
+		}
+		if _, ok := qualifierBefore(src, start); ok {
+			return false
+		}
+		if _, label := fd.namedArgCallee(i); label {
+			return false
+		}
+		if b := fd.lookup(oldName, i); b != nil {
+			return decls[b]
+		}
+		return lambdas[fd.itLambda(i)]
+	})
+	out, n := replaceAt(content, starts, oldName, newName)
+	if len(lambdas) == 0 {
+		return out, n
+	}
+
+	// declare the parameter after each converted lambda's "{", shifted past
+	// the renames before it
+	opens := make([]int, 0, len(lambdas))
+	for s := range lambdas {
+		opens = append(opens, fd.code[s.open].end)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(opens)))
+	for _, pos := range opens {
+		shifted := pos + sort.SearchInts(starts, pos)*(len(newName)-len(oldName))
+		out = out[:shifted] + " " + newName + " ->" + out[shifted:]
+	}
+	return out, n + len(opens)
+}
+
+// declarations returns the bindings of the locals called name that r
+// selects.  Function parameters and members are not locals.
+func (r *LocalRenamer) declarations(fd *fileDecls, name string) map[*binding]bool {
+	decls := make(map[*binding]bool)
+	var walk func(s *scope)
+	walk = func(s *scope) {
+		for _, b := range s.bindings {
+			if b.name != name || b.member || b.param != nil || s.kind == scopeClass || s.kind == scopeFile {
+				continue
+			}
+			if r.Function != "" && !insideFunction(fd, b.tok, r.Function) {
+				continue
+			}
+			if r.Line > 0 && !r.onLine(fd, name, func(i int) bool { return fd.lookup(name, i) == b }) {
+				continue
+			}
+			decls[b] = true
+		}
+		for _, c := range s.children {
+			walk(c)
+		}
+	}
+	walk(fd.root)
+	return decls
+}
+
+// itLambdas returns the lambdas with an implicit it parameter that r
+// selects: those using it inside Function, and on Line those opening there
+// or, if none does, those using it there.  A lambda nested in another
+// selected one is dropped: converting both would shadow the new name.
+func (r *LocalRenamer) itLambdas(fd *fileDecls) map[*scope]bool {
+	opening := make(map[*scope]bool)
+	using := make(map[*scope]bool)
+	for i, t := range fd.code {
+		if t.kind != tokIdent || t.text != "it" || fd.lookup("it", i) != nil {
+			continue
+		}
+		s := fd.itLambda(i)
+		if s == nil || r.Function != "" && !insideFunction(fd, s.open, r.Function) {
+			continue
+		}
+		switch {
+		case r.Line == 0:
+			using[s] = true
+		case r.onLine(fd, "{", func(k int) bool { return k == s.open }):
+			opening[s] = true
+		case r.onLine(fd, "it", func(k int) bool { return k == i }):
+			using[s] = true
+		}
+	}
+	if len(opening) > 0 {
+		return outermost(opening)
+	}
+	return outermost(using)
+}
+
+// outermost removes from lambdas those nested inside another of them.
+func outermost(lambdas map[*scope]bool) map[*scope]bool {
+	for s := range lambdas {
+		for p := s.parent; p != nil; p = p.parent {
+			if lambdas[p] {
+				delete(lambdas, s)
+				break
+			}
+		}
+	}
+	return lambdas
+}
+
+// noParamLambdas are standard functions whose lambda takes no parameter, so
+// an it inside one refers to an enclosing lambda.
+var noParamLambdas = map[string]bool{
+	"run": true, "apply": true, "with": true, "lazy": true, "synchronized": true, "runCatching": true,
+	"buildString": true, "buildList": true, "buildSet": true, "buildMap": true, "thread": true,
+}
+
+// itLambda returns the lambda whose implicit parameter the it at code[i]
+// refers to: the innermost enclosing lambda that declares no parameters
+// and is not passed to one of noParamLambdas.
+func (fd *fileDecls) itLambda(i int) *scope {
+	p := &parser{fd: fd, code: fd.code}
+	for s := fd.scopeAt(i); s != nil; s = s.parent {
+		if s.kind != scopeLambda || p.lambdaArrow(s.open, s.close) >= 0 {
+			continue
+		}
+		callee := s.open - 1
+		if p.text(callee) == ")" {
+			callee = matchingOpen(fd.code, callee) - 1
+		}
+		if !noParamLambdas[p.text(callee)] {
+			return s
+		}
+	}
+	return nil
+}
+
+// onLine reports whether some code token on r.Line (and at r.Column, when
+// given) reading text satisfies match.
+func (r *LocalRenamer) onLine(fd *fileDecls, text string, match func(i int) bool) bool {
+	lo, hi, ok := lineSpan(fd.src, r.Line)
+	if !ok {
+		return false
+	}
+	first := sort.Search(len(fd.code), func(k int) bool { return fd.code[k].end > lo })
+	for i := first; i < len(fd.code) && fd.code[i].start < hi; i++ {
+		t := fd.code[i]
+		if t.start < lo || unquoteIdent(t.text) != text {
+			continue
+		}
+		if r.Column > 0 {
+			col := utf8.RuneCountInString(fd.src[lo:t.start]) + 1
+			if r.Column < col || r.Column > col+utf8.RuneCountInString(t.text) {
+				continue
+			}
+		}
+		if match(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineSpan returns the byte offsets of the start and end of the 1-based
+// line in src.
+func lineSpan(src string, line int) (lo, hi int, ok bool) {
+	for n := 1; n < line; n++ {
+		k := strings.IndexByte(src[lo:], '\n')
+		if k < 0 {
+			return 0, 0, false
+		}
+		lo += k + 1
+	}
+	hi = len(src)
+	if k := strings.IndexByte(src[lo:], '\n'); k >= 0 {
+		hi = lo + k
+	}
+	return lo, hi, true
+}
+
+// insideFunction reports whether code[i] lies in a function called name,
+// however deeply nested.
+func insideFunction(fd *fileDecls, i int, name string) bool {
+	for s := fd.scopeAt(i); s != nil; s = s.parent {
+		if s.kind == scopeFunc && s.fn != nil && s.fn.name == name {
+			return true
+		}
+	}
+	return false
+}
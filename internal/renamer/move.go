@@ -5,13 +5,15 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 )
 
 // MoveOptions controls the kr move command.
 type MoveOptions struct {
-	// FilePath is the absolute (or relative) path to the .kt file to move.
-	FilePath string
+	// FilePaths are the absolute (or relative) paths of the .kt files to
+	// move together.
+	FilePaths []string
 	// NewPackage is the target package, e.g. "com.example.newpackage".
 	NewPackage string
 	// ProjectRoot is used to scan all .kt files for import rewriting.
@@ -22,104 +24,367 @@ type MoveOptions struct {
 
 // MoveResult contains the outcome of a move operation.
 type MoveResult struct {
-	// MovedFrom / MovedTo are file system paths.
-	MovedFrom string
-	MovedTo   string
-	// ImportResults are files whose imports were updated.
+	// Moves are the files moved, in the order given.
+	Moves []FileMove
+	// ImportResults are files whose imports or references were updated,
+	// moved files under their new paths.
 	ImportResults []FileResult
 }
 
-// PackageMove performs the full package move:
-//  1. Rewrites the package declaration in the source file.
-//  2. Scans all .kt files in the project and rewrites imports.
-//  3. Moves the file to the correct directory (unless DryRun).
+// FileMove is one file of a move.
+type FileMove struct {
+	// From / To are file system paths.
+	From string
+	To   string
+	// OldPackage / NewPackage are the file's package before and after.
+	OldPackage string
+	NewPackage string
+}
+
+// PackageMove performs the full package move of a batch of files:
+//  1. Rewrites the package declaration in each moved file.
+//  2. Scans all .kt files in the project once and rewrites the imports of
+//     every top-level declaration in the moved files, and of their nested
+//     classes and companions, and fully-qualified references to them in
+//     code, KDoc and annotations.  Files using them through a wildcard
+//     import of the old package get an explicit import.
+//  3. Adds the imports that same-package references now need: in files of
+//     the old package using the moved declarations, and in the moved files
+//     for what they used from the old package.  Imports that became
+//     same-package are removed.  Files moved together keep seeing each
+//     other wherever they end up.
+//  4. Moves the files to the correct directory (unless DryRun).  Nothing
+//     is written until every file's new content is known, and a failed
+//     write restores the files already written.
 func PackageMove(opts MoveOptions) (*MoveResult, error) {
-	absFile, err := filepath.Abs(opts.FilePath)
-	if err != nil {
-		return nil, fmt.Errorf("resolving file path: %w", err)
+	if len(opts.FilePaths) == 0 {
+		return nil, fmt.Errorf("no files to move")
+	}
+	moves := make([]FileMove, 0, len(opts.FilePaths))
+	for _, path := range opts.FilePaths {
+		moves = append(moves, FileMove{From: path, NewPackage: opts.NewPackage})
 	}
+	return moveFiles(moves, opts.ProjectRoot, opts.DryRun)
+}
+
+// PackageRenameOptions controls the kr rename-package command.
+type PackageRenameOptions struct {
+	// OldPackage is the package prefix to rename, e.g. "com.example.old".
+	// Its subpackages are renamed with it.
+	OldPackage string
+	// NewPackage replaces OldPackage, e.g. "com.example.new".
+	NewPackage string
+	// ProjectRoot is scanned for the files of the package and for import
+	// rewriting.
+	ProjectRoot string
+	// DryRun previews changes without writing.
+	DryRun bool
+}
 
-	if !strings.HasSuffix(absFile, ".kt") {
-		return nil, fmt.Errorf("%s is not a Kotlin file", absFile)
+// RenamePackage renames a package and its subpackages: every file declaring
+// OldPackage or one of its subpackages is moved, as one PackageMove batch,
+// to the same package under NewPackage, so com.old.api becomes com.new.api.
+// Directories left empty under the old package path are removed.
+func RenamePackage(opts PackageRenameOptions) (*MoveResult, error) {
+	oldPkg, newPkg := opts.OldPackage, opts.NewPackage
+	switch {
+	case oldPkg == newPkg:
+		return nil, fmt.Errorf("package %s is already named %s", oldPkg, newPkg)
+	case strings.HasPrefix(newPkg, oldPkg+"."):
+		return nil, fmt.Errorf("cannot rename package %s into its own subpackage %s", oldPkg, newPkg)
 	}
 
-	// ── 1. Read source file ────────────────────────────────────────────────
-	raw, err := os.ReadFile(absFile)
+	files, err := CollectKotlinFiles(ScanOptions{ProjectRoot: opts.ProjectRoot})
 	if err != nil {
-		return nil, fmt.Errorf("reading %s: %w", absFile, err)
+		return nil, fmt.Errorf("scanning project: %w", err)
+	}
+	var moves []FileMove
+	for _, path := range files {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		pkg := extractPackage(string(raw))
+		if pkg == oldPkg || strings.HasPrefix(pkg, oldPkg+".") {
+			moves = append(moves, FileMove{From: path, NewPackage: newPkg + pkg[len(oldPkg):]})
+		}
+	}
+	if len(moves) == 0 {
+		return nil, fmt.Errorf("no files in package %s under %s", oldPkg, opts.ProjectRoot)
 	}
-	srcContent := string(raw)
 
-	// ── 2. Detect current package ──────────────────────────────────────────
-	oldPackage := extractPackage(srcContent)
-	className := strings.TrimSuffix(filepath.Base(absFile), ".kt")
+	result, err := moveFiles(moves, opts.ProjectRoot, opts.DryRun)
+	if err != nil || opts.DryRun {
+		return result, err
+	}
+	for _, m := range result.Moves {
+		removeEmptyDirs(filepath.Dir(m.From), opts.ProjectRoot)
+	}
+	return result, nil
+}
 
-	// ── 3. Rewrite package declaration in source file ──────────────────────
-	newSrcContent := rewritePackageDeclaration(srcContent, opts.NewPackage)
+// relocation is the set of top-level declarations moving from one package
+// to another.
+type relocation struct {
+	from, to string
+	decls    map[string]bool // as returned by topLevelDecls
+}
 
-	// ── 4. Compute new file path ───────────────────────────────────────────
-	newFilePath, err := computeNewPath(opts.ProjectRoot, absFile, opts.NewPackage)
-	if err != nil {
-		return nil, fmt.Errorf("computing new path: %w", err)
+// movePlan is what a batch move does to the project's packages.
+type movePlan struct {
+	relocations []*relocation
+	staying     map[string]map[string]bool // declarations left behind in each package files leave
+	emptied     map[string]bool            // packages files leave and none stays in
+}
+
+// moveFiles moves each file in moves to its NewPackage as one operation,
+// filling in From (made absolute), To and OldPackage.
+func moveFiles(moves []FileMove, projectRoot string, dryRun bool) (*MoveResult, error) {
+	// ── 1. Read the files to move ──────────────────────────────────────────
+	contents := make(map[string]string) // every file involved, by path
+	byPath := make(map[string]*FileMove)
+	for k := range moves {
+		m := &moves[k]
+		absFile, err := filepath.Abs(m.From)
+		if err != nil {
+			return nil, fmt.Errorf("resolving file path: %w", err)
+		}
+		if !strings.HasSuffix(absFile, ".kt") {
+			return nil, fmt.Errorf("%s is not a Kotlin file", absFile)
+		}
+		if byPath[absFile] != nil {
+			return nil, fmt.Errorf("%s is listed more than once", absFile)
+		}
+		raw, err := os.ReadFile(absFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", absFile, err)
+		}
+		contents[absFile] = string(raw)
+		m.From, m.OldPackage = absFile, extractPackage(string(raw))
+		if m.To, err = computeNewPath(projectRoot, absFile, m.NewPackage); err != nil {
+			return nil, fmt.Errorf("computing new path: %w", err)
+		}
+		byPath[absFile] = m
+	}
+
+	// ── 2. Check the destinations ──────────────────────────────────────────
+	targets := make(map[string]string)
+	for _, m := range moves {
+		if other, ok := targets[m.To]; ok {
+			return nil, fmt.Errorf("%s and %s would both move to %s", other, m.From, m.To)
+		}
+		targets[m.To] = m.From
+		if _, err := os.Stat(m.To); err == nil && byPath[m.To] == nil {
+			return nil, fmt.Errorf("cannot move %s: %s already exists", m.From, m.To)
+		}
 	}
 
-	// ── 5. Rewrite imports in all project .kt files ────────────────────────
-	projectFiles, err := CollectKotlinFiles(ScanOptions{ProjectRoot: opts.ProjectRoot})
+	// ── 3. Scan the project once ───────────────────────────────────────────
+	projectFiles, err := CollectKotlinFiles(ScanOptions{ProjectRoot: projectRoot})
 	if err != nil {
 		return nil, fmt.Errorf("scanning project: %w", err)
 	}
+	for _, path := range projectFiles {
+		if _, ok := contents[path]; ok {
+			continue
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		contents[path] = string(raw)
+	}
+	paths := make([]string, 0, len(contents))
+	for path := range contents {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	index := newIndex()
+	for _, path := range paths {
+		index.add(path, contents[path])
+	}
 
-	// Build old and new fully-qualified names
-	var oldFQN, newFQN string
-	if oldPackage != "" {
-		oldFQN = oldPackage + "." + className
-	} else {
-		oldFQN = className
+	// ── 4. Work out where each declaration goes ────────────────────────────
+	plan := &movePlan{staying: make(map[string]map[string]bool), emptied: make(map[string]bool)}
+	for _, m := range moves {
+		if m.OldPackage == m.NewPackage {
+			continue
+		}
+		var r *relocation
+		for _, other := range plan.relocations {
+			if other.from == m.OldPackage && other.to == m.NewPackage {
+				r = other
+			}
+		}
+		if r == nil {
+			r = &relocation{from: m.OldPackage, to: m.NewPackage, decls: make(map[string]bool)}
+			plan.relocations = append(plan.relocations, r)
+		}
+		for name, ext := range topLevelDecls(index.files[m.From]) {
+			r.decls[name] = r.decls[name] || ext
+		}
+		plan.staying[m.OldPackage] = make(map[string]bool)
+		plan.emptied[m.OldPackage] = true
+	}
+	for _, path := range paths {
+		fd := index.files[path]
+		if decls, ok := plan.staying[fd.pkg]; ok && byPath[path] == nil {
+			for name, ext := range topLevelDecls(fd) {
+				decls[name] = decls[name] || ext
+			}
+			delete(plan.emptied, fd.pkg)
+		}
 	}
-	newFQN = opts.NewPackage + "." + className
 
-	// Filter out the source file itself (we've already updated it above)
-	var otherFiles []string
-	for _, f := range projectFiles {
-		if f != absFile {
-			otherFiles = append(otherFiles, f)
+	// ── 5. Rewrite every file ──────────────────────────────────────────────
+	result := &MoveResult{Moves: moves}
+	writes := make(map[string]string)
+	for _, path := range paths {
+		m := byPath[path]
+		out, n := plan.update(contents[path], m)
+		switch {
+		case m != nil:
+			writes[m.To] = out
+			if n > 0 {
+				result.ImportResults = append(result.ImportResults, FileResult{Path: m.To, Replacements: n, NewContent: out})
+			}
+		case n > 0:
+			writes[path] = out
+			result.ImportResults = append(result.ImportResults, FileResult{Path: path, Replacements: n, NewContent: out})
 		}
 	}
 
-	importResults, err := ApplyToFiles(otherFiles, opts.DryRun, func(content string) (string, int) {
-		return rewriteImport(content, oldFQN, newFQN)
-	})
-	if err != nil {
+	if dryRun {
+		return result, nil
+	}
+
+	// ── 6. Write everything, or nothing ────────────────────────────────────
+	var removes []string
+	for _, m := range moves {
+		if _, rewritten := writes[m.From]; !rewritten {
+			removes = append(removes, m.From)
+		}
+	}
+	if err := commitMove(writes, removes, contents); err != nil {
 		return nil, err
 	}
+	return result, nil
+}
 
-	result := &MoveResult{
-		MovedFrom:     absFile,
-		MovedTo:       newFilePath,
-		ImportResults: importResults,
+// update rewrites the content of one project file for the move; m is the
+// file's own move, nil if it stays where it is.  Returns the new content and
+// the number of imports and references changed.
+func (p *movePlan) update(src string, m *FileMove) (string, int) {
+	oldPkg := extractPackage(src)
+	newPkg := oldPkg
+	if m != nil {
+		newPkg = m.NewPackage
 	}
 
-	if opts.DryRun {
-		return result, nil
+	n := 0
+	add := func(out string, k int) {
+		src = out
+		n += k
+	}
+	for _, r := range p.relocations {
+		add(rewriteImports(src, r.from, r.to, r.decls))
+		add(rewriteQualified(src, r.from, r.to, r.decls))
+	}
+	// a wildcard import of a package everything left follows it, when it all
+	// went to one place
+	for pkg := range p.emptied {
+		if to := p.destination(pkg); to != "" {
+			if to == newPkg || importsAll(src, to) {
+				to = ""
+			}
+			add(replaceWildcard(src, pkg, to))
+		}
+	}
+	// references that were same-package, or came through a wildcard import
+	// of the old package, now need an import
+	for _, r := range p.relocations {
+		if r.to != newPkg && (r.from == oldPkg || importsAll(src, r.from) && !importsAll(src, r.to)) {
+			add(importUses(src, r.to, r.decls))
+		}
+	}
+	for pkg := range p.emptied {
+		add(replaceWildcard(src, pkg, ""))
+	}
+	if m == nil {
+		for _, r := range p.relocations {
+			if r.to == newPkg {
+				add(dropImports(src, newPkg, r.decls))
+			}
+		}
+		return src, n
 	}
 
-	// ── 6. Write updated source file to new location ───────────────────────
-	if err := os.MkdirAll(filepath.Dir(newFilePath), 0755); err != nil {
-		return nil, fmt.Errorf("creating directory for %s: %w", newFilePath, err)
+	if oldPkg != newPkg {
+		if oldPkg != "" { // the default package can't be imported from
+			add(importUses(src, oldPkg, p.staying[oldPkg]))
+		}
+		add(dropImports(src, newPkg, nil))
 	}
+	return rewritePackageDeclaration(src, newPkg), n
+}
 
-	if err := os.WriteFile(newFilePath, []byte(newSrcContent), 0644); err != nil {
-		return nil, fmt.Errorf("writing %s: %w", newFilePath, err)
+// destination returns the package everything moved out of pkg went to, or
+// "" when it was split between several.
+func (p *movePlan) destination(pkg string) string {
+	to := ""
+	for _, r := range p.relocations {
+		if r.from != pkg {
+			continue
+		}
+		if to != "" {
+			return ""
+		}
+		to = r.to
 	}
+	return to
+}
 
-	// ── 7. Remove old file (only if it moved to a different path) ──────────
-	if absFile != newFilePath {
-		if err := os.Remove(absFile); err != nil {
-			return nil, fmt.Errorf("removing old file %s: %w", absFile, err)
+// commitMove writes the new contents of a move — files rewritten in place
+// and moved files at their new paths — then removes the moved files' old
+// copies.  If a step fails, everything done so far is undone from
+// originals, the contents read before the move.
+func commitMove(writes map[string]string, removes []string, originals map[string]string) error {
+	var written, removed []string
+	rollback := func(err error) error {
+		for _, path := range written {
+			if orig, ok := originals[path]; ok {
+				_ = os.WriteFile(path, []byte(orig), 0644)
+			} else {
+				_ = os.Remove(path)
+			}
+		}
+		for _, path := range removed {
+			_ = os.WriteFile(path, []byte(originals[path]), 0644)
 		}
+		return err
 	}
 
-	return result, nil
+	paths := make([]string, 0, len(writes))
+	for path := range writes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return rollback(fmt.Errorf("creating directory for %s: %w", path, err))
+		}
+		if err := os.WriteFile(path, []byte(writes[path]), 0644); err != nil {
+			return rollback(fmt.Errorf("writing %s: %w", path, err))
+		}
+		written = append(written, path)
+	}
+	for _, path := range removes {
+		if err := os.Remove(path); err != nil {
+			return rollback(fmt.Errorf("removing old file %s: %w", path, err))
+		}
+		removed = append(removed, path)
+	}
+	return nil
 }
 
 // ─── helpers ──────────────────────────────────────────────────────────────────
@@ -142,17 +407,240 @@ func rewritePackageDeclaration(src, newPackage string) string {
 	return "package " + newPackage + "\n\n" + src
 }
 
-// rewriteImport replaces `import oldFQN` with `import newFQN` in a file.
-// Returns modified content and count of replacements.
-func rewriteImport(content, oldFQN, newFQN string) (string, int) {
-	// Match: import <oldFQN>  optionally followed by end-of-line or whitespace
-	pat := regexp.MustCompile(`(?m)^(import\s+)` + regexp.QuoteMeta(oldFQN) + `(\s*(?:as\s+\w+)?\s*)$`)
-	count := 0
-	result := pat.ReplaceAllStringFunc(content, func(match string) string {
-		count++
-		return pat.ReplaceAllString(match, "${1}"+newFQN+"${2}")
-	})
-	return result, count
+// topLevelDecls returns the names of the declarations fd contributes to its
+// package — top-level classes, interfaces, objects, typealiases, functions
+// and properties — each mapped to whether an extension has that name.
+func topLevelDecls(fd *fileDecls) map[string]bool {
+	decls := make(map[string]bool)
+	add := func(name string, extension bool) {
+		decls[name] = decls[name] || extension
+	}
+	for _, c := range fd.classes {
+		if c.outer == nil && c.name != "" && fd.scopeAt(c.nameTok) == fd.root {
+			add(c.name, false)
+		}
+	}
+	for _, a := range fd.aliases {
+		add(a.name, false)
+	}
+	for _, f := range fd.funcs {
+		if f.owner == nil && !f.ctor && f.name != "" && fd.scopeAt(f.nameTok) == fd.root {
+			add(f.name, f.receiver != "")
+		}
+	}
+	for _, p := range fd.props {
+		if p.owner == nil && !p.local {
+			add(p.name, p.receiver != "")
+		}
+	}
+	return decls
+}
+
+// importUses adds "import pkg.name" to src for each of decls (as returned
+// by topLevelDecls) that src refers to by its simple name — or, for an
+// extension, as a member — without declaring or importing that name itself.
+// Returns the modified content and the number of imports added.
+func importUses(src, pkg string, decls map[string]bool) (string, int) {
+	fd := parseFile(src)
+	n := 0
+	for _, name := range sortedKeys(decls) {
+		if !refersTo(fd, name, decls[name]) {
+			continue
+		}
+		var added bool
+		if src, added = addImport(src, qualify(pkg, name)); added {
+			n++
+		}
+	}
+	return src, n
+}
+
+// refersTo reports whether fd uses name without a package qualifier where
+// only a declaration of another file of its package can supply it.
+func refersTo(fd *fileDecls, name string, extension bool) bool {
+	for _, imp := range fd.imports {
+		if imp.name() == name {
+			return false
+		}
+	}
+	for _, c := range fd.classes {
+		if c.name == name {
+			return false
+		}
+	}
+	for _, a := range fd.aliases {
+		if a.name == name {
+			return false
+		}
+	}
+	for _, f := range fd.funcs {
+		if f.name == name {
+			return false
+		}
+	}
+	for _, p := range fd.props {
+		if p.name == name && !p.local {
+			return false
+		}
+	}
+	for i, t := range fd.code {
+		if t.kind != tokIdent || unquoteIdent(t.text) != name || fd.lookup(name, i) != nil {
+			continue
+		}
+		if _, ok := importAt(fd.imports, t.start); ok {
+			continue
+		}
+		if bareUse(fd, i) {
+			return true
+		}
+		if access, _ := fd.receiverEnd(i); extension && (access == accessDot || access == accessReference) && !declaresName(fd, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// importsAll reports whether src has the wildcard import pkg.*.
+func importsAll(src, pkg string) bool {
+	for _, imp := range parseImports(src) {
+		if imp.wildcard && imp.path == pkg {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteQualified rewrites the fully-qualified references to oldPkg's
+// declarations in decls outside import directives — in code
+// (com.example.User.fromJson(x), val u: com.example.User), annotations
+// and comments such as KDoc links [com.example.User] — to newPkg.  String
+// literals are left alone.  Returns the modified content and the number of
+// references rewritten.
+func rewriteQualified(content, oldPkg, newPkg string, decls map[string]bool) (string, int) {
+	if oldPkg == "" {
+		return content, 0
+	}
+	imports := parseImports(content)
+	pkgDecl := packageDeclPat.FindStringIndex(content)
+	toks := tokenize(content)
+	var starts []int
+	for _, name := range sortedKeys(decls) {
+		starts = append(starts, findMatches(content, toks, name, MatchOptions{IncludeComments: true}, 0, len(content), func(src string, start, end int) bool {
+			if _, ok := importAt(imports, start); ok || pkgDecl != nil && start < pkgDecl[1] {
+				return false
+			}
+			qualifier, ok := qualifierBefore(src, start)
+			return ok && qualifier == oldPkg
+		})...)
+	}
+
+	starts = sortedUnique(starts)
+	var b strings.Builder
+	last := 0
+	for _, start := range starts {
+		if content[start-1] == '`' {
+			start-- // `in`
+		}
+		from, to, _ := qualifierSpan(content, start)
+		b.WriteString(content[last:from])
+		b.WriteString(newPkg)
+		last = to
+	}
+	b.WriteString(content[last:])
+	return b.String(), len(starts)
+}
+
+// replaceWildcard points src's import pkg.* at package to, or removes it
+// when to is "".  Returns the modified content and the number of imports
+// changed.
+func replaceWildcard(src, pkg, to string) (string, int) {
+	for _, imp := range parseImports(src) {
+		if !imp.wildcard || imp.path != pkg {
+			continue
+		}
+		if to == "" {
+			return removeDirective(src, imp.start, imp.end), 1
+		}
+		return src[:imp.start] + "import " + to + ".*" + src[imp.end:], 1
+	}
+	return src, 0
+}
+
+// dropImports removes from src, a file of package pkg, the explicit imports
+// of pkg's declarations in names — or of all of them, and pkg.*, when names
+// is nil — which its package makes redundant.  Aliased imports stay.
+// Returns the modified content and the number of imports removed.
+func dropImports(src, pkg string, names map[string]bool) (string, int) {
+	imports := parseImports(src)
+	n := 0
+	for k := len(imports) - 1; k >= 0; k-- {
+		imp := imports[k]
+		owner, name := splitFQN(imp.path)
+		_, listed := names[name]
+		switch {
+		case imp.alias != "":
+			continue
+		case imp.wildcard:
+			if names != nil || imp.path != pkg {
+				continue
+			}
+		case owner != pkg || names != nil && !listed:
+			continue
+		}
+		src = removeDirective(src, imp.start, imp.end)
+		n++
+	}
+	return src, n
+}
+
+// rewriteImports points the imports of oldPkg's declarations in decls at
+// newPkg: import old.pkg.User, and the nested-class, companion and member
+// imports old.pkg.User.Builder, old.pkg.User.Companion and old.pkg.User.*,
+// aliases kept.  Returns the modified content and the number of imports
+// rewritten.
+func rewriteImports(content, oldPkg, newPkg string, decls map[string]bool) (string, int) {
+	code := codeTokens(tokenize(content))
+	imports := parseImports(content)
+	depth := 0
+	if oldPkg != "" {
+		depth = strings.Count(oldPkg, ".") + 1
+	}
+	n := 0
+	for k := len(imports) - 1; k >= 0; k-- {
+		imp := imports[k]
+		parts := strings.Split(imp.path, ".")
+		if len(parts) <= depth || strings.Join(parts[:depth], ".") != oldPkg {
+			continue
+		}
+		if _, ok := decls[parts[depth]]; !ok {
+			continue
+		}
+		// the path's segments follow "import", separated by dots
+		i := tokenAt(code, imp.start)
+		from, to := code[i+1].start, code[i+1+2*depth].start
+		content = content[:from] + qualify(newPkg, "") + content[to:]
+		n++
+	}
+	return content, n
+}
+
+// removeEmptyDirs removes dir, and then its parents, for as long as they
+// are empty, stopping at projectRoot.  Errors are ignored: a directory that
+// can't be removed is simply left behind.
+func removeEmptyDirs(dir, projectRoot string) {
+	absRoot, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return
+	}
+	for dir != absRoot && strings.HasPrefix(dir, absRoot+string(filepath.Separator)) {
+		if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+			return
+		}
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
 }
 
 // computeNewPath figures out where the file should live after the move.
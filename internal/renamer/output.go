@@ -10,6 +10,8 @@ import (
 //
 //	✅ CartService.kt: 4 replacement(s)
 //	✅ InvoiceService.kt: 2 replacement(s)
+//	⚠️  Checkout.kt:27: cannot infer the receiver type of calculateTotal — left unchanged
+//	1 occurrence(s) left unchanged — review the warnings above
 //	Total: 6 replacement(s) across 2 file(s)
 func PrintResults(w io.Writer, results []FileResult, dryRun bool) {
 	// Sort for deterministic output
@@ -19,6 +21,7 @@ func PrintResults(w io.Writer, results []FileResult, dryRun bool) {
 
 	totalReplacements := 0
 	filesChanged := 0
+	totalWarnings := 0
 
 	for _, r := range results {
 		if r.Err != nil {
@@ -34,6 +37,14 @@ func PrintResults(w io.Writer, results []FileResult, dryRun bool) {
 			totalReplacements += r.Replacements
 			filesChanged++
 		}
+		for _, warn := range r.Warnings {
+			fmt.Fprintf(w, "⚠️  %s:%d: %s\n", r.Path, warn.Line, warn.Message)
+			totalWarnings++
+		}
+	}
+
+	if totalWarnings > 0 {
+		fmt.Fprintf(w, "%d occurrence(s) left unchanged — review the warnings above\n", totalWarnings)
 	}
 
 	if totalReplacements == 0 {
@@ -49,13 +60,35 @@ func PrintResults(w io.Writer, results []FileResult, dryRun bool) {
 		totalReplacements, filesChanged, suffix)
 }
 
+// PrintConflicts writes the conflicts found before a rename to w.  When
+// forced, they are shown as warnings and the rename goes ahead.
+//
+//	❌ Order.kt:3: class Order already exists in package com.example
+//	1 conflict(s) — nothing was renamed; resolve them or rerun with --force
+func PrintConflicts(w io.Writer, conflicts []Conflict, forced bool) {
+	mark := "❌"
+	if forced {
+		mark = "⚠️ "
+	}
+	for _, c := range conflicts {
+		fmt.Fprintf(w, "%s %s:%d: %s\n", mark, c.Path, c.Line, c.Message)
+	}
+	if forced {
+		fmt.Fprintf(w, "%d conflict(s) ignored (--force)\n", len(conflicts))
+	} else {
+		fmt.Fprintf(w, "%d conflict(s) — nothing was renamed; resolve them or rerun with --force\n", len(conflicts))
+	}
+}
+
 // PrintMoveResult writes the move command output.
 func PrintMoveResult(w io.Writer, r *MoveResult, dryRun bool) {
 	verb := "Moved"
 	if dryRun {
 		verb = "Would move"
 	}
-	fmt.Fprintf(w, "%s: %s\n    → %s\n", verb, r.MovedFrom, r.MovedTo)
+	for _, m := range r.Moves {
+		fmt.Fprintf(w, "%s: %s\n    → %s\n", verb, m.From, m.To)
+	}
 
 	if len(r.ImportResults) > 0 {
 		fmt.Fprintln(w, "Import updates:")
@@ -1,6 +1,9 @@
 package renamer
 
 import (
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -123,6 +126,98 @@ data class Result(val value: String)`
 	assertContains(t, got, "data class Outcome")
 }
 
+func TestParseImports(t *testing.T) {
+	src := `package com.app
+
+import com.example.User
+import com.example.Order as Purchase
+import com.other.* // everything
+import java.util.List`
+	imps := parseImports(src)
+	if len(imps) != 4 {
+		t.Fatalf("got %d imports, want 4", len(imps))
+	}
+	if imps[0].path != "com.example.User" || imps[0].name() != "User" {
+		t.Errorf("import 0: %+v", imps[0])
+	}
+	if imps[1].path != "com.example.Order" || imps[1].name() != "Purchase" {
+		t.Errorf("import 1: %+v", imps[1])
+	}
+	if imps[2].path != "com.other" || !imps[2].wildcard {
+		t.Errorf("import 2: %+v", imps[2])
+	}
+}
+
+func TestClassRename_FQN_ExplicitImport(t *testing.T) {
+	r := &ClassRenamer{FQN: "com.example.User"}
+	src := `package com.app
+
+import com.example.User
+
+val u: User = User()`
+	got, n := r.Rename(src, "User", "Account")
+	assertContains(t, got, "import com.example.Account")
+	assertContains(t, got, "val u: Account = Account()")
+	assertCount(t, n, 3)
+}
+
+func TestClassRename_FQN_OtherImportUntouched(t *testing.T) {
+	r := &ClassRenamer{FQN: "com.example.User"}
+	src := `package com.app
+
+import com.other.User
+
+val u: User = com.example.User()`
+	got, n := r.Rename(src, "User", "Account")
+	assertContains(t, got, "import com.other.User")
+	assertContains(t, got, "val u: User = com.example.Account()")
+	assertCount(t, n, 1)
+}
+
+func TestClassRename_FQN_Alias(t *testing.T) {
+	r := &ClassRenamer{FQN: "com.example.User"}
+	src := `import com.example.User as ExUser
+import com.other.User
+
+fun f(a: ExUser, b: User) {}`
+	got, n := r.Rename(src, "User", "Account")
+	assertContains(t, got, "import com.example.Account as ExUser")
+	assertContains(t, got, "import com.other.User")
+	assertContains(t, got, "b: User")
+	assertCount(t, n, 1)
+}
+
+func TestClassRename_FQN_SamePackageAndWildcard(t *testing.T) {
+	r := &ClassRenamer{FQN: "com.example.User"}
+
+	samePkg := `package com.example
+
+class UserService(val user: User)`
+	got, n := r.Rename(samePkg, "User", "Account")
+	assertContains(t, got, "val user: Account")
+	assertCount(t, n, 1)
+
+	wildcard := `package com.app
+
+import com.example.*
+
+val u = User()`
+	got, n = r.Rename(wildcard, "User", "Account")
+	assertContains(t, got, "val u = Account()")
+	assertCount(t, n, 1)
+
+	// A local declaration wins over a wildcard import
+	shadowed := `package com.app
+
+import com.example.*
+
+class User
+val u = User()`
+	got, n = r.Rename(shadowed, "User", "Account")
+	assertNotContains(t, got, "Account")
+	assertCount(t, n, 0)
+}
+
 // ─── Method Rename Tests ───────────────────────────────────────────────────────
 
 func TestMethodRename_Declaration(t *testing.T) {
@@ -162,6 +257,582 @@ func TestMethodRename_DoesNotRenameVariable(t *testing.T) {
 	assertCount(t, n, 0)
 }
 
+func TestMethodRename_ClassScoped(t *testing.T) {
+	r := &MethodRenamer{ClassName: "CartService"}
+	src := `class CartService {
+    fun calculateTotal(): Int = 0
+    fun checkout() = calculateTotal() + this.calculateTotal()
+}
+
+class OrderService {
+    fun calculateTotal(): Int = 1
+    fun refresh() = calculateTotal()
+}
+
+fun main(order: OrderService) {
+    val cart: CartService = load()
+    val c = CartService()
+    cart.calculateTotal()
+    c?.calculateTotal()
+    order.calculateTotal()
+    CartService().calculateTotal()
+    val ref = cart::calculateTotal
+}`
+	got, n := r.Rename(src, "calculateTotal", "computeTotal")
+	assertContains(t, got, "fun computeTotal(): Int = 0")
+	assertContains(t, got, "fun checkout() = computeTotal() + this.computeTotal()")
+	assertContains(t, got, "fun calculateTotal(): Int = 1")
+	assertContains(t, got, "fun refresh() = calculateTotal()")
+	assertContains(t, got, "cart.computeTotal()")
+	assertContains(t, got, "c?.computeTotal()")
+	assertContains(t, got, "order.calculateTotal()")
+	assertContains(t, got, "CartService().computeTotal()")
+	assertContains(t, got, "cart::computeTotal")
+	assertCount(t, n, 7)
+	if len(r.Warnings()) != 0 {
+		t.Errorf("unexpected warnings: %v", r.Warnings())
+	}
+}
+
+func TestMethodRename_MemberExtensionReceiverComesFirst(t *testing.T) {
+	r := &MethodRenamer{ClassName: "CartService"}
+	src := `class Order {
+    fun calculateTotal(): Int = 2
+}
+
+class CartService {
+    fun calculateTotal(): Int = 1
+    fun Order.describe() = this.calculateTotal() + calculateTotal()
+    fun String.label() = this.length + calculateTotal()
+    fun Order.owner() = this@CartService.calculateTotal()
+}`
+	got, n := r.Rename(src, "calculateTotal", "computeTotal")
+	assertContains(t, got, "fun calculateTotal(): Int = 2")
+	assertContains(t, got, "fun computeTotal(): Int = 1")
+	assertContains(t, got, "fun Order.describe() = this.calculateTotal() + calculateTotal()")
+	assertContains(t, got, "fun String.label() = this.length + computeTotal()")
+	assertContains(t, got, "this@CartService.computeTotal()")
+	assertCount(t, n, 3)
+
+	p := &PropertyRenamer{ClassName: "CartService"}
+	got, n = p.Rename(`class Order(val amount: Int)
+
+class CartService(val amount: Int) {
+    fun Order.share() = amount * 100 / this@CartService.amount
+    fun String.scaled() = length * amount
+}`, "amount", "total")
+	assertContains(t, got, "class Order(val amount: Int)")
+	assertContains(t, got, "fun Order.share() = amount * 100 / this@CartService.total")
+	assertContains(t, got, "fun String.scaled() = length * total")
+	assertCount(t, n, 3)
+}
+
+func TestMethodRename_ClassScopedWarnsOnUnknownReceiver(t *testing.T) {
+	r := &MethodRenamer{ClassName: "CartService"}
+	src := `fun main() {
+    repository().calculateTotal()
+    items.first().calculateTotal()
+}`
+	got, n := r.Rename(src, "calculateTotal", "computeTotal")
+	assertNotContains(t, got, "computeTotal")
+	assertCount(t, n, 0)
+	warnings := r.Warnings()
+	if len(warnings) != 2 || warnings[0].Line != 2 || warnings[1].Line != 3 {
+		t.Errorf("expected warnings on lines 2 and 3, got %v", warnings)
+	}
+}
+
+func TestParseFile_ScopesAndBindings(t *testing.T) {
+	src := `class Cart(private val repo: Repo, size: Int) {
+    val items: List<Item> = listOf()
+    fun total(discount: Discount): Int {
+        val calc = Calculator()
+        return items.sumOf { item: Item -> calc.price(item) }
+    }
+}`
+	fd := parseFile(src)
+	if len(fd.classes) != 1 || fd.classes[0].name != "Cart" {
+		t.Fatalf("classes: %+v", fd.classes)
+	}
+	at := func(text string) int {
+		return fd.codeIndex(strings.LastIndex(src, text))
+	}
+	cases := map[string]string{
+		"repo":     "Repo",
+		"items":    "List",
+		"discount": "Discount",
+		"calc":     "Calculator",
+		"item":     "Item",
+	}
+	use := at("item)")
+	for name, want := range cases {
+		b := fd.lookup(name, use)
+		if b == nil || b.typ != want {
+			t.Errorf("lookup(%s): got %+v, want type %s", name, b, want)
+		}
+	}
+	// plain constructor parameters are not visible inside member functions
+	if b := fd.lookup("size", use); b != nil {
+		t.Errorf("size should not be visible in total(), got %+v", b)
+	}
+}
+
+func TestMemberFamily(t *testing.T) {
+	ix := newIndex()
+	ix.add("Repo.kt", `interface Repository {
+    fun save()
+}
+open class SqlRepository : Repository {
+    override fun save() {}
+}
+class CachedRepository : SqlRepository() {
+    override fun save() { super.save() }
+}
+class ReadOnly : Repository, Closeable {
+    override fun save() = error("read only")
+    override fun close() {}
+}`)
+
+	fam, err := ix.MemberFamily("CachedRepository", "save", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(fam.Roots, ",") != "Repository" {
+		t.Errorf("Roots = %v, want [Repository]", fam.Roots)
+	}
+	if got := strings.Join(fam.Declared, ","); got != "CachedRepository,ReadOnly,Repository,SqlRepository" {
+		t.Errorf("Declared = %s", got)
+	}
+
+	if _, err := ix.MemberFamily("ReadOnly", "close", false); err == nil {
+		t.Error("expected an error for an override of an external declaration")
+	}
+	if _, err := ix.MemberFamily("ReadOnly", "load", false); err == nil {
+		t.Error("expected an error for a missing member")
+	}
+}
+
+func TestMethodRename_OverrideFamily(t *testing.T) {
+	ix := newIndex()
+	ix.add("Repo.kt", `interface Repository {
+    fun save()
+}
+open class SqlRepository : Repository {
+    override fun save() {}
+}
+class CachedRepository : SqlRepository() {
+    override fun save() { super.save() }
+}
+class Other {
+    fun save() {}
+}`)
+	r := &MethodRenamer{ClassName: "Repository", Index: ix}
+
+	got, n := r.Rename(ix.files["Repo.kt"].src, "save", "persist")
+	assertContains(t, got, "    fun persist()\n}")
+	assertContains(t, got, "override fun persist() {}")
+	assertContains(t, got, "override fun persist() { super.persist() }")
+	assertContains(t, got, "class Other {\n    fun save() {}")
+	assertCount(t, n, 4)
+
+	got, n = r.Rename(`fun f(repo: CachedRepository, other: Other) {
+    repo.save()
+    other.save()
+}`, "save", "persist")
+	assertContains(t, got, "repo.persist()")
+	assertContains(t, got, "other.save()")
+	assertCount(t, n, 1)
+}
+
+func TestParseSignature(t *testing.T) {
+	cases := map[string]string{
+		"(Long)":                          "(Long)",
+		"()":                              "()",
+		"String, Int":                     "(String, Int)",
+		"(com.example.UserId?, List<T>)":  "(UserId, List)",
+		"(Map<String, Int>, vararg Long)": "(Map, Long)",
+		"((Int) -> Unit)":                 "((Int)->Unit)",
+	}
+	for in, want := range cases {
+		sig, err := ParseSignature(in)
+		if err != nil {
+			t.Errorf("ParseSignature(%q): %v", in, err)
+			continue
+		}
+		if sig.String() != want {
+			t.Errorf("ParseSignature(%q) = %s, want %s", in, sig, want)
+		}
+	}
+	if _, err := ParseSignature("(Map<String, Int)"); err == nil {
+		t.Error("expected an error for unbalanced brackets")
+	}
+}
+
+func TestMethodRename_Signature(t *testing.T) {
+	ix := newIndex()
+	ix.add("UserService.kt", `open class UserService {
+    open fun find(id: Long): User? = null
+    fun find(email: String): User? = null
+    fun find(email: String, active: Boolean = true): User? = null
+}
+class CachedUserService : UserService() {
+    override fun find(id: Long): User? = super.find(id)
+}`)
+	r := &MethodRenamer{ClassName: "UserService", Signature: Signature{"Long"}, Index: ix}
+
+	got, n := r.Rename(ix.files["UserService.kt"].src, "find", "findById")
+	assertContains(t, got, "open fun findById(id: Long)")
+	assertContains(t, got, "fun find(email: String): User?")
+	assertContains(t, got, "override fun findById(id: Long): User? = super.findById(id)")
+	assertCount(t, n, 3)
+
+	got, n = r.Rename(`fun test(svc: UserService, email: String, any: Any?) {
+    svc.find(42)
+    svc.find("a@b.c")
+    svc.find(email)
+    svc.find(id = 7)
+    svc.find(email = "x", active = false)
+    svc.find(any!!)
+}`, "find", "findById")
+	assertContains(t, got, "svc.findById(42)")
+	assertContains(t, got, `svc.find("a@b.c")`)
+	assertContains(t, got, "svc.find(email)")
+	assertContains(t, got, "svc.findById(id = 7)")
+	assertContains(t, got, "svc.find(email = ")
+	assertContains(t, got, "svc.find(any!!)")
+	assertCount(t, n, 2)
+	if w := r.Warnings(); len(w) != 1 || w[0].Line != 7 {
+		t.Errorf("Warnings = %v, want one on line 7", w)
+	}
+}
+
+// ─── Extension Rename Tests ────────────────────────────────────────────────────
+
+func TestExtensionRename_DeclarationsCallsAndImports(t *testing.T) {
+	ix := newIndex()
+	ix.add("util/Strings.kt", `package com.example.util
+
+fun String.toSlug(): String = lowercase().replace(" ", "-")
+fun String.toTitle(): String = toSlug().uppercase()
+val List<Order>.total: Int get() = sumOf { it.amount }`)
+	r := &ExtensionRenamer{Receiver: "String", Index: ix}
+
+	got, n := r.Rename(ix.files["util/Strings.kt"].src, "toSlug", "slugify")
+	assertContains(t, got, "fun String.slugify(): String")
+	assertContains(t, got, "= slugify().uppercase()")
+	assertCount(t, n, 2)
+
+	got, n = r.Rename(`package com.example.app
+
+import com.example.util.toSlug
+import com.example.other.toSlug as other
+
+fun main(title: String, x: Thing) {
+    println(title.toSlug())
+    println("abc".toSlug())
+    val f = String::toSlug
+    println(x.get().toSlug())
+    val toSlug = 1
+}`, "toSlug", "slugify")
+	assertContains(t, got, "import com.example.util.slugify\n")
+	assertContains(t, got, "import com.example.other.toSlug as other")
+	assertContains(t, got, "title.slugify()")
+	assertContains(t, got, `"abc".slugify()`)
+	assertContains(t, got, "String::slugify")
+	assertContains(t, got, "x.get().toSlug()")
+	assertContains(t, got, "val toSlug = 1")
+	assertCount(t, n, 4)
+	if w := r.Warnings(); len(w) != 1 || w[0].Line != 10 {
+		t.Errorf("Warnings = %v, want one on line 10", w)
+	}
+
+	got, n = r.Rename(`fun String.use() = this.toSlug() + toSlug()`, "toSlug", "slugify")
+	assertContains(t, got, "fun String.use() = this.slugify() + slugify()")
+	assertCount(t, n, 2)
+	if w := r.Warnings(); len(w) != 0 {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+
+	r = &ExtensionRenamer{Receiver: "List", Index: ix}
+	got, n = r.Rename(`fun f(orders: List<Order>, order: Order) = orders.total + order.total`, "total", "sum")
+	assertContains(t, got, "orders.sum + order.total")
+	assertCount(t, n, 1)
+}
+
+func TestExtensionRename_PropertyDeclaration(t *testing.T) {
+	ix := newIndex()
+	ix.add("Orders.kt", `package com.example
+
+val List<Order>.total get() = sumOf { it.amount }
+val String?.orEmptyTitle: String get() = this ?: ""
+
+fun report(orders: List<Order>) = orders.total`)
+	if got := ix.ExtensionReceivers("total"); strings.Join(got, ",") != "List" {
+		t.Errorf("ExtensionReceivers(total) = %v, want [List]", got)
+	}
+	if got := ix.ExtensionReceivers("orEmptyTitle"); strings.Join(got, ",") != "String" {
+		t.Errorf("ExtensionReceivers(orEmptyTitle) = %v, want [String]", got)
+	}
+
+	r := &ExtensionRenamer{Receiver: "List", Index: ix}
+	got, n := r.Rename(ix.files["Orders.kt"].src, "total", "sum")
+	assertContains(t, got, "val List<Order>.sum get()")
+	assertContains(t, got, "= orders.sum")
+	assertCount(t, n, 2)
+}
+
+func TestExtensionRename_SharedImportIsKept(t *testing.T) {
+	ix := newIndex()
+	ix.add("util/Slugs.kt", `package com.example.util
+
+fun String.toSlug() = lowercase()
+fun Order.toSlug() = "order-" + id`)
+	r := &ExtensionRenamer{Receiver: "String", Index: ix}
+
+	got, n := r.Rename(`import com.example.util.toSlug
+
+fun f(s: String, o: Order) = s.toSlug() + o.toSlug()`, "toSlug", "slugify")
+	assertContains(t, got, "import com.example.util.toSlug\nimport com.example.util.slugify\n")
+	assertContains(t, got, "s.slugify() + o.toSlug()")
+	assertCount(t, n, 1)
+
+	if got := ix.ExtensionReceivers("toSlug"); strings.Join(got, ",") != "Order,String" {
+		t.Errorf("ExtensionReceivers = %v, want [Order String]", got)
+	}
+}
+
+// ─── Top-level Rename Tests ────────────────────────────────────────────────────
+
+func TestTopLevelRename_ImportsQualifiedAndSamePackage(t *testing.T) {
+	r := &TopLevelRenamer{FQN: "com.example.util.formatDate"}
+
+	got, n := r.Rename(`package com.example.util
+
+fun formatDate(d: Long): String = d.toString()
+fun formatDate(d: Long, pattern: String) = formatDate(d) + pattern
+fun today() = formatDate(0L)`, "formatDate", "formatIsoDate")
+	assertContains(t, got, "fun formatIsoDate(d: Long): String")
+	assertContains(t, got, "= formatIsoDate(d) + pattern")
+	assertContains(t, got, "fun today() = formatIsoDate(0L)")
+	assertCount(t, n, 4)
+
+	got, n = r.Rename(`package com.example.app
+
+import com.example.util.formatDate
+import com.example.util.formatDate as fmt
+
+class Report {
+    fun formatDate(x: Int) = "member"
+    fun render() = formatDate(1)
+}
+
+fun main() {
+    println(formatDate(1L) + fmt(2L))
+    println(com.example.util.formatDate(3L))
+    val f = ::formatDate
+    val formatDate = "local"
+    println(formatDate)
+}`, "formatDate", "formatIsoDate")
+	assertContains(t, got, "import com.example.util.formatIsoDate\n")
+	assertContains(t, got, "import com.example.util.formatIsoDate as fmt")
+	assertContains(t, got, `fun formatDate(x: Int) = "member"`)
+	assertContains(t, got, "fun render() = formatDate(1)")
+	assertContains(t, got, "println(formatIsoDate(1L) + fmt(2L))")
+	assertContains(t, got, "com.example.util.formatIsoDate(3L)")
+	assertContains(t, got, "val f = ::formatIsoDate")
+	assertContains(t, got, "val formatDate = \"local\"\n    println(formatDate)")
+	assertCount(t, n, 5)
+}
+
+func TestTopLevelRename_VisibilityByImport(t *testing.T) {
+	r := &TopLevelRenamer{FQN: "com.example.util.DEFAULT_TIMEOUT"}
+
+	got, n := r.Rename(`import com.example.util.*
+
+val t = DEFAULT_TIMEOUT`, "DEFAULT_TIMEOUT", "TIMEOUT_SECONDS")
+	assertContains(t, got, "val t = TIMEOUT_SECONDS")
+	assertCount(t, n, 1)
+
+	got, n = r.Rename(`import com.example.util.*
+import com.example.other.DEFAULT_TIMEOUT
+
+val t = DEFAULT_TIMEOUT`, "DEFAULT_TIMEOUT", "TIMEOUT_SECONDS")
+	assertNotContains(t, got, "TIMEOUT_SECONDS")
+	assertCount(t, n, 0)
+
+	ix := newIndex()
+	ix.add("a/Util.kt", "package com.example.util\n\nconst val DEFAULT_TIMEOUT = 30")
+	ix.add("b/Other.kt", "package com.example.other\n\nval DEFAULT_TIMEOUT = 10\nfun String.DEFAULT_TIMEOUT() = 1")
+	if got := ix.TopLevelDeclarations("DEFAULT_TIMEOUT"); strings.Join(got, ",") != "com.example.other.DEFAULT_TIMEOUT,com.example.util.DEFAULT_TIMEOUT" {
+		t.Errorf("TopLevelDeclarations = %v", got)
+	}
+}
+
+// ─── Enum Entry Rename Tests ───────────────────────────────────────────────────
+
+func TestEnumEntryRename_DeclarationAndUses(t *testing.T) {
+	r := &EnumEntryRenamer{ClassName: "Status"}
+
+	got, n := r.Rename(`package com.example
+
+enum class Status(val label: String) {
+    PENDING("p"),
+    DONE("d") {
+        override fun next() = PENDING
+    };
+
+    open fun next(): Status = DONE
+    fun isPending() = this == PENDING
+}
+
+enum class Priority { PENDING, HIGH }`, "PENDING", "AWAITING")
+	assertContains(t, got, `AWAITING("p"),`)
+	assertContains(t, got, "override fun next() = AWAITING")
+	assertContains(t, got, "fun isPending() = this == AWAITING")
+	assertContains(t, got, "enum class Priority { PENDING, HIGH }")
+	assertCount(t, n, 3)
+
+	got, n = r.Rename(`import com.example.Status
+import com.example.Status.PENDING
+
+fun describe(s: Status) = when (s) {
+    PENDING -> "waiting"
+    Status.DONE -> "done"
+}
+
+fun main() {
+    val a = com.example.Status.PENDING
+    val p = Priority.PENDING
+    println(Status.entries + Status.values())
+    val PENDING = 3
+    println(PENDING)
+}`, "PENDING", "AWAITING")
+	assertContains(t, got, "import com.example.Status.AWAITING\n")
+	assertContains(t, got, `AWAITING -> "waiting"`)
+	assertContains(t, got, "val a = com.example.Status.AWAITING")
+	assertContains(t, got, "val p = Priority.PENDING")
+	assertContains(t, got, "Status.entries + Status.values()")
+	assertContains(t, got, "val PENDING = 3\n    println(PENDING)")
+	assertCount(t, n, 3)
+
+	got, n = r.Rename(`import com.example.Priority.*
+
+val x = PENDING`, "PENDING", "AWAITING")
+	assertNotContains(t, got, "AWAITING")
+	assertCount(t, n, 0)
+}
+
+func TestEnumEntryRename_ValueOfIsReported(t *testing.T) {
+	r := &EnumEntryRenamer{ClassName: "Status"}
+	got, n := r.Rename(`import com.example.Status.*
+
+val a = Status.valueOf("PENDING")
+val b = enumValueOf<Status>("PENDING")
+val c = Priority.valueOf("PENDING")
+val d = PENDING`, "PENDING", "AWAITING")
+	assertContains(t, got, `Status.valueOf("PENDING")`)
+	assertContains(t, got, "val d = AWAITING")
+	assertCount(t, n, 1)
+	if w := r.Warnings(); len(w) != 2 || w[0].Line != 3 || w[1].Line != 4 {
+		t.Errorf("expected warnings on lines 3 and 4, got %v", w)
+	}
+
+	ix := newIndex()
+	ix.add("Status.kt", "enum class Status { PENDING, DONE }\nclass Order")
+	if got, ok := ix.EnumEntries("Status"); !ok || strings.Join(got, ",") != "DONE,PENDING" {
+		t.Errorf("EnumEntries(Status) = %v, %v", got, ok)
+	}
+	if _, ok := ix.EnumEntries("Order"); ok {
+		t.Error("Order is not an enum class")
+	}
+}
+
+// ─── Typealias and Type Parameter Rename Tests ─────────────────────────────────
+
+func TestTypeAliasRename_DeclarationImportsAndTypeUses(t *testing.T) {
+	r := &TypeAliasRenamer{FQN: "com.example.model.UserId"}
+
+	got, n := r.Rename(`package com.example.model
+
+typealias UserId = String
+fun parse(s: String): UserId = s`, "UserId", "AccountId")
+	assertContains(t, got, "typealias AccountId = String")
+	assertContains(t, got, "fun parse(s: String): AccountId = s")
+	assertCount(t, n, 2)
+
+	got, n = r.Rename(`package com.example.app
+
+import com.example.model.UserId
+
+class Request(val UserId: String)
+
+fun load(id: UserId, req: Request): List<UserId> {
+    val x = req.UserId
+    return listOf(id as UserId, com.example.model.UserId())
+}`, "UserId", "AccountId")
+	assertContains(t, got, "import com.example.model.AccountId\n")
+	assertContains(t, got, "class Request(val UserId: String)")
+	assertContains(t, got, "fun load(id: AccountId, req: Request): List<AccountId>")
+	assertContains(t, got, "val x = req.UserId")
+	assertContains(t, got, "listOf(id as AccountId, com.example.model.AccountId())")
+	assertCount(t, n, 5)
+
+	got, n = r.Rename(`package com.example.other
+
+typealias UserId = Long
+val id: UserId = 1L`, "UserId", "AccountId")
+	assertNotContains(t, got, "AccountId")
+	assertCount(t, n, 0)
+
+	ix := newIndex()
+	ix.add("a/Types.kt", "package com.example.model\n\ntypealias UserId = String")
+	ix.add("b/Types.kt", "package com.example.other\n\ntypealias UserId = Long")
+	if got := ix.TypeAliases("UserId"); strings.Join(got, ",") != "com.example.model.UserId,com.example.other.UserId" {
+		t.Errorf("TypeAliases = %v", got)
+	}
+}
+
+func TestTypeParameterRename_ScopedToDeclaration(t *testing.T) {
+	src := `class Box<T : Comparable<T>>(val item: T) where T : Any {
+    fun get(): T = item
+    fun <T> convert(x: T): T = x
+    fun <R> map(f: (T) -> R): Box<R> = TODO()
+    inner class Holder(val t: T)
+    class Nested<T>(val t: T)
+    abstract fun <T> abs(x: T): List<T> where T : Any
+}
+
+fun <T> first(items: List<T>): T {
+    val T = 3
+    return items[0] as T
+}
+
+typealias Predicate<T> = (T) -> Boolean`
+
+	got, n := (&TypeParameterRenamer{ClassName: "Box"}).Rename(src, "T", "TItem")
+	assertContains(t, got, "class Box<TItem : Comparable<TItem>>(val item: TItem) where TItem : Any {")
+	assertContains(t, got, "fun get(): TItem = item")
+	assertContains(t, got, "fun <T> convert(x: T): T = x")
+	assertContains(t, got, "fun <R> map(f: (TItem) -> R)")
+	assertContains(t, got, "inner class Holder(val t: TItem)")
+	assertContains(t, got, "class Nested<T>(val t: T)")
+	assertContains(t, got, "fun <T> first(items: List<T>): T")
+	assertCount(t, n, 7)
+
+	got, n = (&TypeParameterRenamer{Function: "abs"}).Rename(src, "T", "E")
+	assertContains(t, got, "abstract fun <E> abs(x: E): List<E> where E : Any")
+	assertCount(t, n, 4)
+
+	got, n = (&TypeParameterRenamer{Function: "first"}).Rename(src, "T", "E")
+	assertContains(t, got, "fun <E> first(items: List<E>): E {")
+	assertContains(t, got, "val T = 3\n    return items[0] as E")
+	assertCount(t, n, 4)
+
+	got, n = (&TypeParameterRenamer{ClassName: "Predicate"}).Rename(src, "T", "E")
+	assertContains(t, got, "typealias Predicate<E> = (E) -> Boolean")
+	assertCount(t, n, 2)
+}
+
 // ─── Property Rename Tests ─────────────────────────────────────────────────────
 
 func TestPropertyRename_Declaration(t *testing.T) {
@@ -222,6 +893,56 @@ func TestPropertyRename_DoesNotRenameMethodCall(t *testing.T) {
 	assertCount(t, n, 0)
 }
 
+func TestPropertyRename_ClassScoped(t *testing.T) {
+	ix := newIndex()
+	ix.add("User.kt", `open class User(val id: Long) {
+    fun describe(id: String) = "user " + id
+    fun key() = id * 31
+}`)
+	ix.add("Admin.kt", `class Admin : User(1) {
+    fun audit() = id
+}`)
+	r := &PropertyRenamer{ClassName: "User", Index: ix}
+
+	got, n := r.Rename(ix.files["User.kt"].src, "id", "userId")
+	assertContains(t, got, "class User(val userId: Long)")
+	assertContains(t, got, `fun describe(id: String) = "user " + id`)
+	assertContains(t, got, "fun key() = userId * 31")
+	assertCount(t, n, 2)
+
+	got, n = r.Rename(ix.files["Admin.kt"].src, "id", "userId")
+	assertContains(t, got, "fun audit() = userId")
+	assertCount(t, n, 1)
+
+	src := `class Order(val id: Long)
+
+fun f(order: Order, admin: Admin, user: User) {
+    val id = 5
+    println(order.id + admin.id + user.id + id)
+    val u = User(id = 7)
+    val o = Order(id = 8)
+}`
+	got, n = r.Rename(src, "id", "userId")
+	assertContains(t, got, "class Order(val id: Long)")
+	assertContains(t, got, "order.id + admin.userId + user.userId + id)")
+	assertContains(t, got, "User(userId = 7)")
+	assertContains(t, got, "Order(id = 8)")
+	assertContains(t, got, "val id = 5")
+	assertCount(t, n, 3)
+}
+
+func TestPropertyOwners(t *testing.T) {
+	ix := newIndex()
+	ix.add("A.kt", `class A(val id: Int)
+class B { var id = 0 }
+class C : A(1) { override val id = 2 }
+fun local() { val id = 3 }`)
+	owners := ix.PropertyOwners("id")
+	if strings.Join(owners, ",") != "A,B" {
+		t.Errorf("PropertyOwners = %v, want [A B]", owners)
+	}
+}
+
 // ─── Parameter Rename Tests ────────────────────────────────────────────────────
 
 func TestParameterRename_SignatureAndBody(t *testing.T) {
@@ -253,20 +974,782 @@ fun greet(name: String): String {
 	}
 }
 
+func TestParameterRename_NamedArgumentCallSites(t *testing.T) {
+	ix := newIndex()
+	ix.add("UserService.kt", `class UserService {
+    fun createUser(userId: Long, name: String = "") {
+        audit(userId = userId)
+    }
+}
+fun audit(userId: Long) = println(userId)`)
+	r := &ParameterRenamer{Function: "createUser", Index: ix}
+
+	got, n := r.Rename(ix.files["UserService.kt"].src, "userId", "accountId")
+	assertContains(t, got, "fun createUser(accountId: Long")
+	assertContains(t, got, "audit(userId = accountId)")
+	assertContains(t, got, "fun audit(userId: Long) = println(userId)")
+	assertCount(t, n, 2)
+
+	got, n = r.Rename(`fun main(svc: UserService) {
+    svc.createUser(userId = 1, name = "a")
+    UserService().createUser(name = "b", userId = 2)
+    audit(userId = 3)
+}`, "userId", "accountId")
+	assertContains(t, got, "svc.createUser(accountId = 1")
+	assertContains(t, got, "createUser(name = \"b\", accountId = 2)")
+	assertContains(t, got, "audit(userId = 3)")
+	assertCount(t, n, 2)
+}
+
+func TestParameterRename_OverridesAndAmbiguousCalls(t *testing.T) {
+	ix := newIndex()
+	ix.add("Repo.kt", `interface Repository {
+    fun save(id: Long)
+}
+class SqlRepository : Repository {
+    override fun save(id: Long) {}
+}
+class Cache {
+    fun save(id: Long, ttl: Int) {}
+}`)
+	r := &ParameterRenamer{ClassName: "Repository", Index: ix}
+
+	got, n := r.Rename(ix.files["Repo.kt"].src, "id", "key")
+	assertContains(t, got, "    fun save(key: Long)\n}")
+	assertContains(t, got, "override fun save(key: Long) {}")
+	assertContains(t, got, "fun save(id: Long, ttl: Int)")
+	assertCount(t, n, 2)
+
+	got, n = r.Rename(`fun f(repo: SqlRepository, cache: Cache, any: Any) {
+    repo.save(id = 1)
+    cache.save(id = 2, ttl = 5)
+    lookup().save(id = 3)
+    lookup().save(id = 4, ttl = 5)
+    any.save(id = 5)
+}`, "id", "key")
+	assertContains(t, got, "repo.save(key = 1)")
+	assertContains(t, got, "cache.save(id = 2")
+	assertContains(t, got, "lookup().save(key = 3)")
+	assertContains(t, got, "lookup().save(id = 4")
+	assertContains(t, got, "any.save(id = 5)")
+	assertCount(t, n, 2)
+	if w := r.Warnings(); len(w) != 1 || w[0].Line != 6 {
+		t.Errorf("Warnings = %v, want one on line 6", w)
+	}
+}
+
+func TestParameterRename_ConstructorParameters(t *testing.T) {
+	ix := newIndex()
+	ix.add("Mailer.kt", `class Mailer(customerId: String, retries: Int = customerId.length) : Base(customerId) {
+    private val key = customerId.uppercase()
+    init {
+        println(customerId)
+    }
+    constructor(customerId: Long) : this(customerId = customerId.toString(), retries = 1) {
+        println(customerId)
+    }
+    fun send() = key
+}
+class Other(customerId: String)`)
+	r := &ParameterRenamer{Function: "Mailer", Index: ix}
+
+	got, n := r.Rename(ix.files["Mailer.kt"].src, "customerId", "clientId")
+	assertContains(t, got, "class Mailer(clientId: String, retries: Int = clientId.length) : Base(clientId)")
+	assertContains(t, got, "private val key = clientId.uppercase()")
+	assertContains(t, got, "        println(clientId)\n    }\n    constructor")
+	assertContains(t, got, "constructor(clientId: Long) : this(clientId = clientId.toString(), retries = 1)")
+	assertContains(t, got, "class Other(customerId: String)")
+	assertCount(t, n, 9)
+
+	got, n = r.Rename(`val m = Mailer(customerId = "c")
+val o = Other(customerId = "d")`, "customerId", "clientId")
+	assertContains(t, got, `Mailer(clientId = "c")`)
+	assertContains(t, got, `Other(customerId = "d")`)
+	assertCount(t, n, 1)
+}
+
+func TestParameterRename_DataClassPropertyAndCopy(t *testing.T) {
+	ix := newIndex()
+	ix.add("Invoice.kt", `data class Invoice(val customerId: String, val total: Int) {
+    val label = "invoice for $customerId"
+    fun describe() = customerId + this.customerId
+}`)
+	r := &ParameterRenamer{ClassName: "Invoice", Index: ix}
+
+	got, n := r.Rename(ix.files["Invoice.kt"].src, "customerId", "clientId")
+	assertContains(t, got, "data class Invoice(val clientId: String")
+	assertContains(t, got, `"invoice for $clientId"`)
+	assertContains(t, got, "fun describe() = clientId + this.clientId")
+	assertCount(t, n, 4)
+
+	got, n = r.Rename(`fun main(any: Any) {
+    val inv = Invoice(customerId = "c", total = 1)
+    val next = inv.copy(customerId = "d")
+    println(inv.customerId)
+    lookup().copy(customerId = "e")
+}`, "customerId", "clientId")
+	assertContains(t, got, `Invoice(clientId = "c"`)
+	assertContains(t, got, `inv.copy(clientId = "d")`)
+	assertContains(t, got, "println(inv.clientId)")
+	assertContains(t, got, `lookup().copy(customerId = "e")`)
+	assertCount(t, n, 3)
+	if w := r.Warnings(); len(w) != 1 || w[0].Line != 5 {
+		t.Errorf("Warnings = %v, want one on line 5", w)
+	}
+}
+
+// ─── Local Rename Tests ────────────────────────────────────────────────────────
+
+const localSrc = `class Cart(val items: List<Item>) {
+    val total: Int = 0
+
+    fun compute(order: Order): Int {
+        var total = 0
+        for (item in items) {
+            total += item.price
+        }
+        if (total > 100) {
+            val total = total - 10
+            println("discounted $total")
+        }
+        items.forEach { item ->
+            val total = item.price * 2
+            println(total)
+        }
+        log(total = total, order.total)
+        return total + this.total
+    }
+
+    fun other(): Int {
+        val total = 5
+        for ((total, n) in listOf(1 to 2)) println(total)
+        return total
+    }
+}`
+
+func TestLocalRename_ShadowingAndMembers(t *testing.T) {
+	got, n := (&LocalRenamer{Line: 5}).Rename(localSrc, "total", "sum")
+	assertContains(t, got, "val total: Int = 0")
+	assertContains(t, got, "var sum = 0")
+	assertContains(t, got, "sum += item.price")
+	assertContains(t, got, "if (sum > 100) {\n            val total = sum - 10\n            println(\"discounted $total\")")
+	assertContains(t, got, "val total = item.price * 2\n            println(total)")
+	assertContains(t, got, "log(total = sum, order.total)")
+	assertContains(t, got, "return sum + this.total")
+	assertContains(t, got, "val total = 5")
+	assertCount(t, n, 6)
+
+	// a line that only references the inner local selects it
+	got, n = (&LocalRenamer{Line: 11}).Rename(localSrc, "total", "discounted")
+	assertContains(t, got, "val discounted = total - 10\n            println(\"discounted $discounted\")")
+	assertCount(t, n, 2)
+}
+
+func TestLocalRename_ForLoopAndDestructuring(t *testing.T) {
+	got, n := (&LocalRenamer{Function: "other"}).Rename(localSrc, "total", "sum")
+	assertContains(t, got, "val sum = 5")
+	assertContains(t, got, "for ((sum, n) in listOf(1 to 2)) println(sum)")
+	assertContains(t, got, "return sum\n")
+	assertContains(t, got, "var total = 0")
+	assertCount(t, n, 4)
+
+	got, n = (&LocalRenamer{Line: 6}).Rename(localSrc, "item", "line")
+	assertContains(t, got, "for (line in items) {\n            total += line.price")
+	assertContains(t, got, "items.forEach { item ->")
+	assertCount(t, n, 2)
+
+	got, n = (&LocalRenamer{Line: 2}).Rename(`fun f(pair: Pair<Int, Int>) {
+    val (first, second) = pair
+    println(first + second + pair.first)
+}`, "first", "a")
+	assertContains(t, got, "val (a, second) = pair\n    println(a + second + pair.first)")
+	assertCount(t, n, 2)
+}
+
+func TestLocalRename_LambdaParametersAndIt(t *testing.T) {
+	src := `fun names(users: List<User>, groups: Map<String, List<User>>) {
+    val a = users.map { it.name }
+    val b = users.filter { it.active }.map { user -> user.name }
+    groups.forEach { (key, members) ->
+        println(key + members.size)
+    }
+    users.forEach {
+        println(it.name)
+        it.tags.map { it.length }
+        it.roles.map { r -> r + it.id }
+        run { println(it.id) }
+    }
+}`
+
+	got, n := (&LocalRenamer{Line: 4}).Rename(src, "members", "users2")
+	assertContains(t, got, "groups.forEach { (key, users2) ->\n        println(key + users2.size)")
+	assertCount(t, n, 2)
+
+	got, n = (&LocalRenamer{Line: 3}).Rename(src, "user", "u")
+	assertContains(t, got, ".map { u -> u.name }")
+	assertCount(t, n, 2)
+
+	got, n = (&LocalRenamer{Line: 7}).Rename(src, "it", "user")
+	assertContains(t, got, "users.forEach { user ->\n        println(user.name)")
+	assertContains(t, got, "user.tags.map { it.length }")
+	assertContains(t, got, "user.roles.map { r -> r + user.id }")
+	assertContains(t, got, "run { println(user.id) }")
+	assertContains(t, got, "val a = users.map { it.name }")
+	assertCount(t, n, 6)
+
+	got, n = (&LocalRenamer{Line: 9}).Rename(src, "it", "tag")
+	assertContains(t, got, "it.tags.map { tag -> tag.length }")
+	assertContains(t, got, "println(it.name)")
+	assertCount(t, n, 2)
+
+	// by function, only the outermost lambdas using it are converted
+	got, _ = (&LocalRenamer{Function: "names"}).Rename(src, "it", "user")
+	assertContains(t, got, "val a = users.map { user -> user.name }")
+	assertContains(t, got, "users.forEach { user ->\n        println(user.name)")
+	assertContains(t, got, "user.tags.map { it.length }")
+
+	got, n = (&LocalRenamer{Function: "f"}).Rename(`fun f(users: List<String>) = users.map { it.length + listOf(1).map { it + 1 }.size }`, "it", "user")
+	assertContains(t, got, "users.map { user -> user.length + listOf(1).map { it + 1 }.size }")
+	assertCount(t, n, 2)
+}
+
+// ─── Symbol Resolution Tests ──────────────────────────────────────────────────
+
+func TestSymbolAt_DeclarationsAndReferences(t *testing.T) {
+	ix := newIndex()
+	ix.add("User.kt", `package com.example
+
+import com.example.util.formatDate
+
+open class User(val name: String) {
+    open fun greet(prefix: String): String = prefix + name
+}
+
+class Admin(name: String) : User(name) {
+    override fun greet(prefix: String): String = "admin " + prefix
+}
+
+enum class Status { PENDING, DONE }
+
+typealias UserId = Long
+
+fun <T> firstOf(items: List<T>): T = items.first()
+
+fun String.slug(): String = formatDate(this)`)
+	ix.add("Dates.kt", `package com.example.util
+
+fun formatDate(s: String): String = s`)
+	ix.add("Service.kt", `package com.example
+
+class Service {
+    fun run(user: User, id: UserId): String {
+        val s = user.greet("hi")
+        val names = listOf(user).map { it.name }
+        fun helper(x: Int) = x + Status.PENDING.ordinal
+        return s + helper(1) + "x".slug() + firstOf(names)
+    }
+}`)
+
+	cases := []struct {
+		path         string
+		line, column int
+		want         Symbol
+	}{
+		{"User.kt", 5, 12, Symbol{Kind: "class", FQN: "com.example.User", Decl: Position{"User.kt", 5, 12}}},
+		{"User.kt", 5, 21, Symbol{Kind: "parameter", ClassName: "User", Function: "User", Decl: Position{"User.kt", 5, 21}}},
+		{"User.kt", 6, 21, Symbol{Kind: "parameter", ClassName: "User", Function: "greet", Decl: Position{"User.kt", 6, 20}}},
+		{"User.kt", 6, 55, Symbol{Kind: "parameter", ClassName: "User", Function: "User", Decl: Position{"User.kt", 5, 21}}},
+		{"User.kt", 13, 21, Symbol{Kind: "enum-entry", ClassName: "Status", Decl: Position{"User.kt", 13, 21}}},
+		{"User.kt", 15, 11, Symbol{Kind: "typealias", FQN: "com.example.UserId", Decl: Position{"User.kt", 15, 11}}},
+		{"User.kt", 17, 29, Symbol{Kind: "type-parameter", Function: "firstOf", FileLocal: true, Decl: Position{"User.kt", 17, 6}}},
+		{"User.kt", 3, 25, Symbol{Kind: "top-level", FQN: "com.example.util.formatDate", Decl: Position{"Dates.kt", 3, 5}}},
+		{"Service.kt", 4, 19, Symbol{Kind: "class", FQN: "com.example.User", Decl: Position{"User.kt", 5, 12}}},
+		{"Service.kt", 4, 29, Symbol{Kind: "typealias", FQN: "com.example.UserId", Decl: Position{"User.kt", 15, 11}}},
+		{"Service.kt", 5, 22, Symbol{Kind: "method", ClassName: "User", Decl: Position{"User.kt", 6, 14}}},
+		{"Service.kt", 5, 22 + len("greet"), Symbol{Kind: "method", ClassName: "User", Decl: Position{"User.kt", 6, 14}}},
+		{"Service.kt", 5, 17, Symbol{Kind: "parameter", ClassName: "Service", Function: "run", Decl: Position{"Service.kt", 4, 13}}},
+		{"Service.kt", 6, 40, Symbol{Kind: "local", FileLocal: true, Decl: Position{"Service.kt", 6, 38}}},
+		{"Service.kt", 7, 41, Symbol{Kind: "enum-entry", ClassName: "Status", Decl: Position{"User.kt", 13, 21}}},
+		{"Service.kt", 8, 20, Symbol{Kind: "method", FileLocal: true, Decl: Position{"Service.kt", 7, 13}}},
+		{"Service.kt", 8, 16, Symbol{Kind: "local", FileLocal: true, Decl: Position{"Service.kt", 5, 13}}},
+		{"Service.kt", 8, 36, Symbol{Kind: "extension", ClassName: "String", Decl: Position{"User.kt", 19, 12}}},
+		{"Service.kt", 8, 45, Symbol{Kind: "top-level", FQN: "com.example.firstOf", Decl: Position{"User.kt", 17, 9}}},
+	}
+	for _, c := range cases {
+		got, err := ix.SymbolAt(c.path, c.line, c.column)
+		if err != nil {
+			t.Errorf("%s:%d:%d: %v", c.path, c.line, c.column, err)
+			continue
+		}
+		got.Name, got.Package, got.Start, got.End = "", "", Position{}, Position{}
+		if *got != c.want {
+			t.Errorf("%s:%d:%d: got %+v, want %+v", c.path, c.line, c.column, *got, c.want)
+		}
+	}
+
+	for _, at := range []struct{ line, column int }{{6, 38}, {20, 1}, {4, 0}} {
+		if _, err := ix.SymbolAt("Service.kt", at.line, at.column); err == nil {
+			t.Errorf("Service.kt:%d:%d: expected an error", at.line, at.column)
+		}
+	}
+}
+
+func TestDeclarations_KindsAndPackages(t *testing.T) {
+	ix := newIndex()
+	ix.add("a/Cache.kt", `package com.example.a
+
+object Cache {
+    fun clear(cache: Map<String, Int>) {
+        val size = cache.size
+    }
+}`)
+	ix.add("b/Cache.kt", `package com.example.b
+
+fun size(): Int = 0`)
+
+	cases := map[string]string{
+		"Cache":   "object com.example.a com.example.a.Cache a/Cache.kt:3:8",
+		"clear":   "method com.example.a Cache a/Cache.kt:4:9",
+		"cache":   "parameter com.example.a Cache a/Cache.kt:4:15",
+		"size":    "local com.example.a  a/Cache.kt:5:13; top-level com.example.b com.example.b.size b/Cache.kt:3:5",
+		"missing": "",
+	}
+	for name, want := range cases {
+		var got []string
+		for _, d := range ix.Declarations(name) {
+			got = append(got, strings.Join([]string{d.Kind, d.Package, d.ClassName + d.FQN, d.Decl.String()}, " "))
+		}
+		if strings.Join(got, "; ") != want {
+			t.Errorf("Declarations(%q) = %q, want %q", name, strings.Join(got, "; "), want)
+		}
+	}
+}
+
+func TestLocalRename_ColumnPicksOneLambda(t *testing.T) {
+	src := `fun f(xs: List<String>) = xs.map { it.trim() }.filter { it.isNotEmpty() }`
+
+	got, n := (&LocalRenamer{Line: 1, Column: 55}).Rename(src, "it", "s")
+	assertContains(t, got, "xs.map { it.trim() }.filter { s -> s.isNotEmpty() }")
+	assertCount(t, n, 2)
+}
+
+// ─── Conflict Tests ───────────────────────────────────────────────────────────
+
+func conflictMessages(r Renamer, ix *Index, oldName, newName string) string {
+	var paths []string
+	for path := range ix.files {
+		paths = append(paths, path)
+	}
+	var out []string
+	for _, c := range FindConflicts(r, ix, paths, oldName, newName) {
+		out = append(out, c.Path+":"+strconv.Itoa(c.Line)+": "+c.Message)
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestFindConflicts_DeclarationsAndImports(t *testing.T) {
+	ix := newIndex()
+	ix.add("User.kt", `package com.example
+
+class User(val name: String, val email: String) {
+    fun greet(prefix: String): String = prefix + name
+    fun greet(count: Int): String = "x"
+    fun welcome(prefix: String): String = prefix
+}
+
+class Order
+
+enum class Status { PENDING, DONE }`)
+	ix.add("Use.kt", `package com.other
+
+import com.example.User
+import com.third.Account
+
+fun use(u: User): Account? = null`)
+
+	cases := []struct {
+		r        Renamer
+		old, new string
+		want     string
+	}{
+		{&ClassRenamer{FQN: "com.example.User"}, "User", "Order", "User.kt:9: class Order already exists in package com.example"},
+		{&ClassRenamer{FQN: "com.example.User"}, "User", "Account", "Use.kt:4: imports com.third.Account, which would clash with the renamed com.example.User"},
+		{&ClassRenamer{FQN: "com.example.User"}, "User", "Customer", ""},
+		{&MethodRenamer{ClassName: "User", Signature: Signature{"String"}, Index: ix}, "greet", "welcome", "User.kt:6: User already declares welcome(String)"},
+		{&MethodRenamer{ClassName: "User", Signature: Signature{"Int"}, Index: ix}, "greet", "welcome", ""},
+		{&PropertyRenamer{ClassName: "User", Index: ix}, "name", "email", "User.kt:3: User already declares property email"},
+		{&EnumEntryRenamer{ClassName: "Status"}, "PENDING", "DONE", "User.kt:11: enum class Status already has an entry DONE"},
+		{&EnumEntryRenamer{ClassName: "Status"}, "PENDING", "WAITING", ""},
+	}
+	for _, c := range cases {
+		if got := conflictMessages(c.r, ix, c.old, c.new); got != c.want {
+			t.Errorf("%T %s -> %s:\ngot:  %q\nwant: %q", c.r, c.old, c.new, got, c.want)
+		}
+	}
+}
+
+func TestFindConflicts_Shadowing(t *testing.T) {
+	ix := newIndex()
+	ix.add("Cart.kt", `class Cart(val items: List<Int>) {
+    fun total(count: Int): Int {
+        val sum = count + 1
+        if (sum > 0) {
+            val label = sum
+            return label + items.size
+        }
+        return sum
+    }
+    fun size(): Int {
+        val items = 3
+        return count() + items
+    }
+    fun count(): Int = 0
+}`)
+
+	cases := []struct {
+		r        Renamer
+		old, new string
+		want     string
+	}{
+		{&ParameterRenamer{Function: "total"}, "count", "sum", "Cart.kt:3: sum declared here would shadow the renamed count"},
+		{&ParameterRenamer{Function: "total"}, "count", "items", "Cart.kt:6: items here refers to another declaration, which the renamed count would shadow"},
+		{&LocalRenamer{Function: "total"}, "sum", "label", "Cart.kt:5: label declared here would shadow the renamed sum"},
+		{&LocalRenamer{Function: "total"}, "sum", "count", "Cart.kt:3: the renamed sum would shadow count declared on line 2"},
+		{&LocalRenamer{Function: "total"}, "sum", "subtotal", ""},
+		{&MethodRenamer{ClassName: "Cart", Index: ix}, "count", "items", "Cart.kt:12: items here would refer to the local items declared on line 11"},
+	}
+	for _, c := range cases {
+		if got := conflictMessages(c.r, ix, c.old, c.new); got != c.want {
+			t.Errorf("%T %s -> %s:\ngot:  %q\nwant: %q", c.r, c.old, c.new, got, c.want)
+		}
+	}
+}
+
+func TestFindConflicts_ItToNamedParameter(t *testing.T) {
+	ix := newIndex()
+	ix.add("Users.kt", `fun show(users: List<Int>) {
+    users.forEach { println(it + users.size) }
+}
+fun tally(users: List<Int>) {
+    users.forEach { val user = 1; println(it + user) }
+}`)
+
+	cases := []struct {
+		r        Renamer
+		old, new string
+		want     string
+	}{
+		{&LocalRenamer{Function: "show"}, "it", "users", "Users.kt:2: users here refers to another declaration, which the renamed it would shadow"},
+		{&LocalRenamer{Line: 2}, "it", "users", "Users.kt:2: users here refers to another declaration, which the renamed it would shadow"},
+		{&LocalRenamer{Function: "tally"}, "it", "user", "Users.kt:5: user declared here would shadow the renamed it"},
+		{&LocalRenamer{Function: "show"}, "it", "user", ""},
+	}
+	for _, c := range cases {
+		if got := conflictMessages(c.r, ix, c.old, c.new); got != c.want {
+			t.Errorf("%T %s -> %s:\ngot:  %q\nwant: %q", c.r, c.old, c.new, got, c.want)
+		}
+	}
+}
+
+// ─── Identifier Tests ─────────────────────────────────────────────────────────
+
+func TestValidateIdentifier_KeywordsAndUnicode(t *testing.T) {
+	valid := []string{"user", "_items", "Größe", "名前", "value", "data", "open", "`fun`", "`creates a user`"}
+	invalid := []string{"fun", "when", "in", "1user", "user-name", "___", "``", "`a`b`"}
+	for _, s := range valid {
+		if err := ValidateIdentifier(s); err != nil {
+			t.Errorf("ValidateIdentifier(%q): %v", s, err)
+		}
+	}
+	for _, s := range invalid {
+		if err := ValidateIdentifier(s); err == nil {
+			t.Errorf("ValidateIdentifier(%q): expected an error", s)
+		}
+	}
+	if err := ValidateIdentifier("fun"); err == nil || !strings.Contains(err.Error(), "`fun`") {
+		t.Errorf("keyword error should offer backticks, got %v", err)
+	}
+	if err := ValidateName("type-parameter", "out"); err == nil {
+		t.Errorf("ValidateName(type-parameter, out): expected an error")
+	}
+	if err := ValidateName("property", "out"); err != nil {
+		t.Errorf("ValidateName(property, out): %v", err)
+	}
+
+	for pkg, ok := range map[string]bool{"com.example.util": true, "com.example.when": false, "com..example": false, "": false} {
+		if err := ValidatePackageName(pkg); (err == nil) != ok {
+			t.Errorf("ValidatePackageName(%q) = %v", pkg, err)
+		}
+	}
+}
+
+func TestNamingWarning_Conventions(t *testing.T) {
+	cases := []struct {
+		kind, name, want string
+	}{
+		{"class", "UserAccount", ""},
+		{"class", "user_account", "e.g. UserAccount"},
+		{"method", "fetchUser", ""},
+		{"method", "FetchUser", "e.g. fetchUser"},
+		{"property", "_items", ""},
+		{"const", "MAX_RETRIES", ""},
+		{"const", "maxRetries", "e.g. MAX_RETRIES"},
+		{"top-level", "MAX_RETRIES", "e.g. maxRetries"},
+		{"enum-entry", "Pending", ""},
+		{"enum-entry", "in_progress", "e.g. IN_PROGRESS"},
+		{"package", "com.Example.util", "e.g. com.example.util"},
+		{"method", "`creates a user`", ""},
+	}
+	for _, c := range cases {
+		got := NamingWarning(c.kind, c.name)
+		if c.want == "" && got != "" || c.want != "" && !strings.Contains(got, c.want) {
+			t.Errorf("NamingWarning(%s, %s) = %q, want %q", c.kind, c.name, got, c.want)
+		}
+	}
+}
+
+func TestClassRename_UnicodeWordBoundaries(t *testing.T) {
+	src := `class Größe
+val a: Größe = Größe()
+val b = GrößeX()
+val c = XGröße()`
+	got, n := (&ClassRenamer{}).Rename(src, "Größe", "Maß")
+	assertContains(t, got, "val a: Maß = Maß()")
+	assertContains(t, got, "GrößeX()")
+	assertContains(t, got, "XGröße()")
+	assertCount(t, n, 3)
+
+	got, n = (&ClassRenamer{}).Rename("val x = Userß + User", "User", "Account")
+	assertContains(t, got, "Userß + Account")
+	assertCount(t, n, 1)
+}
+
+func TestClassRename_BacktickNames(t *testing.T) {
+	src := "class User\n" +
+		"class UserTest {\n" +
+		"    fun `creates a User when email is valid`() {\n" +
+		"        val u = `User`()\n" +
+		"    }\n" +
+		"    fun `Users are listed`() = `creates a User when email is valid`()\n" +
+		"}\n" +
+		"/** Builds a `User`. */"
+	got, n := (&ClassRenamer{MatchOptions: MatchOptions{IncludeComments: true}}).Rename(src, "User", "Account")
+	assertContains(t, got, "class Account\n")
+	assertContains(t, got, "val u = Account()")
+	assertContains(t, got, "Builds a `Account`.")
+	assertContains(t, got, "fun `creates a User when email is valid`()")
+	assertNotContains(t, got, "``")
+	assertCount(t, n, 3)
+
+	got, n = (&ClassRenamer{TestNames: true}).Rename(src, "User", "Account")
+	assertContains(t, got, "fun `creates a Account when email is valid`()")
+	assertContains(t, got, "= `creates a Account when email is valid`()")
+	assertContains(t, got, "fun `Users are listed`()")
+	assertContains(t, got, "class UserTest")
+	assertCount(t, n, 4)
+}
+
+func TestTopLevelRename_QuotedNames(t *testing.T) {
+	src := "package com.example\n" +
+		"import com.example.`in`\n" +
+		"fun `in`(xs: List<Int>) {\n" +
+		"    for (x in xs) `in`(listOf(x))\n" +
+		"}\n" +
+		"fun `creates user`() {}\n" +
+		"fun test() = `creates user`()"
+	got, n := (&TopLevelRenamer{FQN: "com.example.in"}).Rename(src, "in", "within")
+	assertContains(t, got, "import com.example.within\n")
+	assertContains(t, got, "fun within(xs")
+	assertContains(t, got, "for (x in xs) within(listOf(x))")
+	assertCount(t, n, 3)
+
+	got, n = (&PropertyRenamer{}).Rename("class A(val `in`: Int) {\n    fun f() = `in` + this.`in`\n}", "in", "inside")
+	assertContains(t, got, "class A(val inside: Int)")
+	assertContains(t, got, "fun f() = inside + this.inside")
+	assertCount(t, n, 3)
+
+	got, n = (&TopLevelRenamer{FQN: "com.example.creates user"}).Rename(src, "creates user", "`creates a user`")
+	assertContains(t, got, "fun `creates a user`() {}")
+	assertContains(t, got, "fun test() = `creates a user`()")
+	assertCount(t, n, 2)
+
+	got, n = (&MethodRenamer{}).Rename("fun test() {}\nfun `test it`() = test()", "test", "`check`")
+	assertContains(t, got, "fun `check`() {}")
+	assertContains(t, got, "fun `test it`() = `check`()")
+	assertCount(t, n, 2)
+}
+
+// ─── Lexer Tests ───────────────────────────────────────────────────────────────
+
+func TestTokenize_CommentsAndLiterals(t *testing.T) {
+	src := `/* outer /* nested User */ still comment */
+val a = "User \" quoted" // User
+val b = """raw "User" text"""
+val c = 'U'
+val d = "${names["User"]} done"`
+	var kinds []tokenKind
+	for _, tok := range tokenize(src) {
+		if strings.Contains(tok.text, "User") {
+			kinds = append(kinds, tok.kind)
+		}
+	}
+	want := []tokenKind{tokBlockComment, tokString, tokLineComment, tokString, tokString}
+	if len(kinds) != len(want) {
+		t.Fatalf("got %d tokens containing User, want %d: %v", len(kinds), len(want), kinds)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Errorf("token %d: kind %d, want %d", i, kinds[i], want[i])
+		}
+	}
+}
+
+func TestClassRename_SkipsCommentsAndStrings(t *testing.T) {
+	r := &ClassRenamer{}
+	src := `// TODO User cleanup
+/* User docs */
+val sql = "SELECT * FROM User"
+val u: User = User()`
+	got, n := r.Rename(src, "User", "Account")
+	assertContains(t, got, "// TODO User cleanup")
+	assertContains(t, got, "/* User docs */")
+	assertContains(t, got, `"SELECT * FROM User"`)
+	assertContains(t, got, "val u: Account = Account()")
+	assertCount(t, n, 2)
+}
+
+func TestClassRename_IncludeCommentsAndStrings(t *testing.T) {
+	src := `// User
+val s = "User"`
+	r := &ClassRenamer{MatchOptions: MatchOptions{IncludeComments: true}}
+	got, n := r.Rename(src, "User", "Account")
+	assertContains(t, got, "// Account")
+	assertContains(t, got, `"User"`)
+	assertCount(t, n, 1)
+
+	r = &ClassRenamer{MatchOptions: MatchOptions{IncludeStrings: true}}
+	got, n = r.Rename(src, "User", "Account")
+	assertContains(t, got, "// User")
+	assertContains(t, got, `"Account"`)
+	assertCount(t, n, 1)
+}
+
+func TestParameterRename_IgnoresBracesInLiterals(t *testing.T) {
+	r := &ParameterRenamer{}
+	src := `fun greet(userId: String): String {
+    val open = "{ userId"
+    return userId
+}
+
+fun other() = userId`
+	got, n := r.Rename(src, "userId", "accountId")
+	assertContains(t, got, `"{ userId"`)
+	assertContains(t, got, "return accountId")
+	assertContains(t, got, "fun other() = userId")
+	assertCount(t, n, 2)
+}
+
+// ─── String Template Tests ─────────────────────────────────────────────────────
+
+func TestParameterRename_TemplatesButNotLiteralText(t *testing.T) {
+	r := &ParameterRenamer{}
+	src := `fun check(userId: String?) {
+    require(userId != null) { "userId is required" }
+    log("id=$userId, upper=${userId.uppercase()}")
+}`
+	got, n := r.Rename(src, "userId", "accountId")
+	assertContains(t, got, `"userId is required"`)
+	assertContains(t, got, `"id=$accountId, upper=${accountId.uppercase()}"`)
+	assertCount(t, n, 4)
+}
+
+func TestPropertyRename_TemplateFollowedByText(t *testing.T) {
+	r := &PropertyRenamer{}
+	src := `val msg = "$name is ${user.name}"`
+	got, n := r.Rename(src, "name", "title")
+	assertContains(t, got, `"$title is ${user.title}"`)
+	assertCount(t, n, 2)
+}
+
+func TestMethodRename_TemplateNestedStringsAndBraces(t *testing.T) {
+	r := &MethodRenamer{}
+	src := `val s = "total: ${cart.calculateTotal(mapOf("k" to "}").let { it })} calculateTotal()"
+val t = "$calculateTotal()"`
+	got, n := r.Rename(src, "calculateTotal", "computeTotal")
+	assertContains(t, got, "${cart.computeTotal(mapOf")
+	assertContains(t, got, `} calculateTotal()"`)
+	// "$calculateTotal()" references a property; the parens are literal text
+	assertContains(t, got, `"$calculateTotal()"`)
+	assertCount(t, n, 1)
+}
+
+func TestClassRename_TemplateExpression(t *testing.T) {
+	r := &ClassRenamer{}
+	src := `val s = "User: ${User.DEFAULT.name} (User)"`
+	got, n := r.Rename(src, "User", "Account")
+	assertContains(t, got, `"User: ${Account.DEFAULT.name} (User)"`)
+	assertCount(t, n, 1)
+}
+
 // ─── Package Move Tests ────────────────────────────────────────────────────────
 
-func TestRewriteImport(t *testing.T) {
+func TestRewriteImports(t *testing.T) {
 	src := `import com.example.User
 import com.example.UserService
 import com.other.Foo`
 
-	got, n := rewriteImport(src, "com.example.User", "com.example.newpkg.User")
+	got, n := rewriteImports(src, "com.example", "com.example.newpkg", map[string]bool{"User": false})
 	assertContains(t, got, "import com.example.newpkg.User")
 	assertContains(t, got, "import com.example.UserService") // NOT changed
 	assertContains(t, got, "import com.other.Foo")           // NOT changed
 	assertCount(t, n, 1)
 }
 
+func TestRewriteImports_AllTopLevelDeclarations(t *testing.T) {
+	decls := topLevelDecls(parseFile(`package com.example
+class User { class Builder; companion object { fun create() = User() } }
+class Order
+fun Order.total() = 0
+val Order.discount: Int get() = 1
+val List<Order>.weight get() = 2
+const val MAX = 3
+typealias Users = List<User>`))
+	src := `import com.example.User.Companion
+import com.example.User.Builder
+import com.example.User.Companion.create
+import com.example.User.*
+import com.example.Order as O
+import com.example.total
+import com.example.discount
+import com.example.weight
+import com.example.MAX
+import com.example.Users
+import com.example.Models
+import com.example.other.User`
+
+	got, n := rewriteImports(src, "com.example", "com.shop", decls)
+	for _, want := range []string{
+		"import com.shop.User.Companion\n",
+		"import com.shop.User.Builder\n",
+		"import com.shop.User.Companion.create\n",
+		"import com.shop.User.*\n",
+		"import com.shop.Order as O\n",
+		"import com.shop.total\n",
+		"import com.shop.discount\n",
+		"import com.shop.weight\n",
+		"import com.shop.MAX\n",
+		"import com.shop.Users\n",
+		"import com.example.Models\n",
+		"import com.example.other.User",
+	} {
+		assertContains(t, got, want)
+	}
+	assertCount(t, n, 10)
+	if !decls["discount"] || !decls["weight"] {
+		t.Errorf("extension properties not recorded as extensions: %v", decls)
+	}
+}
+
 func TestExtractPackage(t *testing.T) {
 	src := `package com.example.foo
 
@@ -286,8 +1769,203 @@ class Foo`
 	assertNotContains(t, got, "package com.example.old")
 }
 
+func TestRewriteQualified_CodeKDocAndAnnotations(t *testing.T) {
+	decls := map[string]bool{"User": false, "Marker": false, "in": false}
+	src := `package com.example.api
+
+import com.example.User
+
+/** Returns a [com.example.User], not a [com.example.UserService]. */
+@com.example.Marker
+fun load(x: String): com.example.User {
+    val u: com . example.User = com.example.User.fromJson(x)
+    com.example.` + "`in`" + `(u)
+    return other.com.example.User(u.com.example)
+}
+val s = "com.example.User"`
+	got, n := rewriteQualified(src, "com.example", "com.shop", decls)
+	assertContains(t, got, "import com.example.User\n")
+	assertContains(t, got, "Returns a [com.shop.User], not a [com.example.UserService]")
+	assertContains(t, got, "@com.shop.Marker\n")
+	assertContains(t, got, "fun load(x: String): com.shop.User {")
+	assertContains(t, got, "val u: com . example.User = com.shop.User.fromJson(x)")
+	assertContains(t, got, "com.shop.`in`(u)")
+	assertContains(t, got, "other.com.example.User(")
+	assertContains(t, got, `val s = "com.example.User"`)
+	assertCount(t, n, 5)
+
+	if !importsAll("import com.example.*\nimport com.other.Foo", "com.example") || importsAll("import com.example.User", "com.example") {
+		t.Error("importsAll misreads the wildcard import")
+	}
+}
+
+func TestPackageMove_Batch(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src", "main", "kotlin", "com", "example")
+	files := map[string]string{
+		"billing/Invoice.kt": "package com.example.billing\n\nclass Invoice(val lines: List<Line>) {\n    fun total() = lines.sumOf { it.amount } + fee(this)\n}\n",
+		"billing/Line.kt":    "package com.example.billing\n\ndata class Line(val amount: Int)\n",
+		"billing/Fees.kt":    "package com.example.billing\n\nfun fee(i: Invoice) = 1\n",
+		"app/App.kt":         "package com.example.app\n\nimport com.example.billing.Invoice\nimport com.example.billing.*\n\nval x = Invoice(listOf(Line(1))).total() + fee(Invoice(emptyList()))\n",
+	}
+	writeTree(t, src, files)
+
+	result, err := PackageMove(MoveOptions{
+		FilePaths:   []string{filepath.Join(src, "billing", "Invoice.kt"), filepath.Join(src, "billing", "Line.kt")},
+		NewPackage:  "com.example.payments",
+		ProjectRoot: root,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Moves) != 2 || result.Moves[1].To != filepath.Join(src, "payments", "Line.kt") {
+		t.Errorf("unexpected moves %+v", result.Moves)
+	}
+	got := readFile(t, filepath.Join(src, "payments/Invoice.kt"))
+	assertContains(t, got, "package com.example.payments\n\nimport com.example.billing.fee\n\nclass Invoice")
+	assertNotContains(t, got, "import com.example.payments.Line")
+	assertContains(t, readFile(t, filepath.Join(src, "billing/Fees.kt")), "import com.example.payments.Invoice\n")
+	assertContains(t, readFile(t, filepath.Join(src, "app/App.kt")), "import com.example.payments.Invoice\nimport com.example.billing.*\nimport com.example.payments.Line\n")
+	if _, err := os.Stat(filepath.Join(src, "billing", "Line.kt")); !os.IsNotExist(err) {
+		t.Errorf("billing/Line.kt was not removed")
+	}
+
+	// moving the rest empties com.example.billing: its wildcard import follows
+	if _, err := PackageMove(MoveOptions{FilePaths: []string{filepath.Join(src, "billing", "Fees.kt")}, NewPackage: "com.example.payments", ProjectRoot: root}); err != nil {
+		t.Fatal(err)
+	}
+	got = readFile(t, filepath.Join(src, "app/App.kt"))
+	assertContains(t, got, "import com.example.payments.*\n")
+	assertNotContains(t, got, "billing")
+	assertNotContains(t, readFile(t, filepath.Join(src, "payments/Invoice.kt")), "import")
+
+	// a destination that already exists stops the whole batch
+	if err := os.WriteFile(filepath.Join(src, "app", "Line.kt"), []byte("package com.example.app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = PackageMove(MoveOptions{
+		FilePaths:   []string{filepath.Join(src, "payments", "Invoice.kt"), filepath.Join(src, "payments", "Line.kt")},
+		NewPackage:  "com.example.app",
+		ProjectRoot: root,
+	})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected an already-exists error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(src, "payments", "Invoice.kt")); err != nil {
+		t.Errorf("payments/Invoice.kt was moved despite the error")
+	}
+}
+
+func TestRenamePackage_Subpackages(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"src/main/kotlin/com/old/Service.kt":     "package com.old\n\nimport com.old.api.Client\n\nclass Service(val c: Client)\n",
+		"src/main/kotlin/com/old/api/Client.kt":  "package com.old.api\n\nimport com.old.*\n\nclass Client {\n    fun s(): Service? = null\n}\n",
+		"src/main/kotlin/com/older/Keep.kt":      "package com.older\n\nclass Keep\n",
+		"src/main/kotlin/com/app/Main.kt":        "package com.app\n\nimport com.old.api.*\nimport com.older.Keep\n\nval s = com.old.Service(Client())\n",
+		"src/test/kotlin/com/old/ServiceTest.kt": "package com.old\n\nclass ServiceTest { val s = Service(com.old.api.Client()) }\n",
+	}
+	writeTree(t, root, files)
+
+	result, err := RenamePackage(PackageRenameOptions{OldPackage: "com.old", NewPackage: "com.fresh", ProjectRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Moves) != 3 {
+		t.Errorf("expected 3 moves, got %+v", result.Moves)
+	}
+	assertContains(t, readFile(t, filepath.Join(root, "src/main/kotlin/com/fresh/Service.kt")), "package com.fresh\n\nimport com.fresh.api.Client\n")
+	assertContains(t, readFile(t, filepath.Join(root, "src/main/kotlin/com/fresh/api/Client.kt")), "package com.fresh.api\n\nimport com.fresh.*\n")
+	assertContains(t, readFile(t, filepath.Join(root, "src/test/kotlin/com/fresh/ServiceTest.kt")), "package com.fresh\n\nclass ServiceTest { val s = Service(com.fresh.api.Client()) }")
+	got := readFile(t, filepath.Join(root, "src/main/kotlin/com/app/Main.kt"))
+	assertContains(t, got, "import com.fresh.api.*\nimport com.older.Keep\n\nval s = com.fresh.Service(Client())")
+	assertNotContains(t, got, "com.old.")
+	assertContains(t, readFile(t, filepath.Join(root, "src/main/kotlin/com/older/Keep.kt")), "package com.older\n")
+	for _, dir := range []string{"src/main/kotlin/com/old", "src/test/kotlin/com/old"} {
+		if _, err := os.Stat(filepath.Join(root, dir)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", dir)
+		}
+	}
+
+	if _, err := RenamePackage(PackageRenameOptions{OldPackage: "com.fresh", NewPackage: "com.fresh.inner", ProjectRoot: root}); err == nil {
+		t.Errorf("expected an error renaming a package into its own subpackage")
+	}
+	if _, err := RenamePackage(PackageRenameOptions{OldPackage: "com.old", NewPackage: "com.other", ProjectRoot: root}); err == nil {
+		t.Errorf("expected an error for a package with no files")
+	}
+}
+
+func TestImportUses_SamePackageReferences(t *testing.T) {
+	moved := topLevelDecls(parseFile(`package com.example
+class User(val name: String)
+fun User.slug() = name.lowercase()
+const val MAX = 3`))
+	if got := strings.Join(sortedKeys(moved), ","); got != "MAX,User,slug" || !moved["slug"] || moved["User"] {
+		t.Errorf("topLevelDecls = %v", moved)
+	}
+
+	sibling := `package com.example
+
+class Service(private val repo: Repo) {
+    fun run(u: User) = u.slug()
+    fun limit(MAX: Int) = MAX
+}`
+	got, n := importUses(sibling, "com.example.users", moved)
+	assertContains(t, got, "package com.example\n\nimport com.example.users.User\nimport com.example.users.slug\n\nclass Service")
+	assertNotContains(t, got, "users.MAX")
+	assertCount(t, n, 2)
+
+	// a file declaring or importing the name doesn't need the import
+	got, n = importUses("package com.example\nimport com.other.User\nval u = User()", "com.example.users", moved)
+	assertCount(t, n, 0)
+}
+
+func TestDropImports_SamePackage(t *testing.T) {
+	src := `package com.example.users
+
+import com.example.users.User
+import com.example.users.Order
+import com.example.users.Order as O
+import com.example.users.User.Builder
+import com.example.users.*
+import com.example.Repo
+
+class Service`
+	got, n := dropImports(src, "com.example.users", map[string]bool{"User": false})
+	assertNotContains(t, got, "import com.example.users.User\n")
+	assertContains(t, got, "import com.example.users.Order\n")
+	assertCount(t, n, 1)
+
+	got, n = dropImports(src, "com.example.users", nil)
+	assertContains(t, got, "package com.example.users\n\nimport com.example.users.Order as O\nimport com.example.users.User.Builder\nimport com.example.Repo\n\nclass Service")
+	assertCount(t, n, 3)
+}
+
 // ─── helpers ──────────────────────────────────────────────────────────────────
 
+// writeTree writes files, keyed by slash-separated paths relative to root.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
 func assertContains(t *testing.T, got, want string) {
 	t.Helper()
 	if !strings.Contains(got, want) {
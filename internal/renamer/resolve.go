@@ -0,0 +1,499 @@
+package renamer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Position is a place in a source file.  Line and Column are 1-based, and
+// Column counts characters.
+type Position struct {
+	Path   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.Path, p.Line, p.Column)
+}
+
+// Symbol is the declaration an identifier refers to, described the way
+// kr rename addresses symbols.
+type Symbol struct {
+	Kind      string // a --type value, e.g. "method" or "local"
+	Name      string
+	ClassName string   // declaring class, enum class or extension receiver
+	Function  string   // function declaring a parameter or type parameter
+	FQN       string   // fully-qualified name of a top-level class, declaration or typealias
+	Package   string   // package of the declaring file
+	FileLocal bool     // only the declaring file can refer to it: locals, type parameters, local functions and their parameters
+	Decl      Position // the declaration's name, or for an implicit it the lambda's "{"
+	Start     Position // the identifier at the requested position
+	End       Position // just past it
+}
+
+// SymbolAt finds the identifier at line:column of the indexed file path and
+// resolves it to its declaration, like an editor's rename action.
+func (ix *Index) SymbolAt(path string, line, column int) (*Symbol, error) {
+	fd := ix.files[path]
+	if fd == nil {
+		return nil, fmt.Errorf("%s is not among the scanned files", path)
+	}
+	pos, ok := offsetOf(fd.src, line, column)
+	if !ok {
+		return nil, fmt.Errorf("%s:%d:%d is outside the file", path, line, column)
+	}
+	i := tokenAt(fd.code, pos)
+	if (i < 0 || fd.code[i].kind != tokIdent) && pos > 0 {
+		i = tokenAt(fd.code, pos-1) // just past the identifier
+	}
+	if i < 0 || fd.code[i].kind != tokIdent {
+		return nil, fmt.Errorf("no identifier at %s:%d:%d", path, line, column)
+	}
+
+	sym := ix.declarationAt(fd, i)
+	if sym == nil {
+		var err error
+		if sym, err = ix.referenceAt(fd, i); err != nil {
+			return nil, err
+		}
+	}
+	sym.Name = unquoteIdent(fd.code[i].text)
+	sym.Start = positionOf(fd, fd.code[i].start)
+	sym.End = positionOf(fd, fd.code[i].end)
+	return sym, nil
+}
+
+// ─── declarations ─────────────────────────────────────────────────────────────
+
+// Declarations returns every declaration called name in the indexed files —
+// classes, functions, properties, typealiases, enum entries, parameters,
+// type parameters and locals — ordered by file and position.
+func (ix *Index) Declarations(name string) []*Symbol {
+	var decls []*Symbol
+	for _, path := range sortedKeys(ix.pathSet()) {
+		fd := ix.files[path]
+		for i, t := range fd.code {
+			if t.kind != tokIdent || unquoteIdent(t.text) != name {
+				continue
+			}
+			if sym := ix.declarationAt(fd, i); sym != nil {
+				sym.Start, sym.End = sym.Decl, positionOf(fd, t.end)
+				decls = append(decls, sym)
+			}
+		}
+	}
+	return decls
+}
+
+// declarationAt classifies code[i] when it is the name in a declaration,
+// and returns nil otherwise.
+func (ix *Index) declarationAt(fd *fileDecls, i int) *Symbol {
+	sym := ix.classifyDeclaration(fd, i)
+	if sym != nil {
+		sym.Name = unquoteIdent(fd.code[i].text)
+		sym.Package = fd.pkg
+		sym.Decl = positionOf(fd, fd.code[i].start)
+	}
+	return sym
+}
+
+func (ix *Index) classifyDeclaration(fd *fileDecls, i int) *Symbol {
+	for _, c := range fd.classes {
+		switch {
+		case c.nameTok == i:
+			sym := &Symbol{Kind: c.kind}
+			if c.outer == nil {
+				sym.FQN = qualify(fd.pkg, c.name)
+			}
+			return sym
+		case hasInt(c.entries, i):
+			return &Symbol{Kind: "enum-entry", ClassName: c.name}
+		}
+		for _, f := range c.constructors() {
+			if paramTok(f, i) {
+				return &Symbol{Kind: "parameter", Function: c.name, ClassName: c.name}
+			}
+		}
+	}
+	for _, a := range fd.aliases {
+		if a.nameTok == i {
+			return &Symbol{Kind: "typealias", FQN: qualify(fd.pkg, a.name)}
+		}
+	}
+	for _, tp := range fd.typeParams {
+		if tp.nameTok == i {
+			return typeParamSymbol(tp)
+		}
+	}
+	for _, f := range fd.funcs {
+		switch {
+		case f.nameTok == i:
+			return funcSymbol(fd, f)
+		case paramTok(f, i):
+			sym := &Symbol{Kind: "parameter", Function: f.name}
+			switch {
+			case f.owner != nil:
+				sym.ClassName = f.owner.name
+			case fd.scopeAt(f.nameTok) != fd.root:
+				sym.FileLocal = true // a local function's
+			}
+			return sym
+		}
+	}
+	for _, p := range fd.props {
+		if p.nameTok == i {
+			return propSymbol(fd, p)
+		}
+	}
+	// for-loop variables and lambda parameters
+	if b := fd.lookup(unquoteIdent(fd.code[i].text), i); b != nil && b.tok == i {
+		return &Symbol{Kind: "local", FileLocal: true}
+	}
+	return nil
+}
+
+func funcSymbol(fd *fileDecls, f *funcDecl) *Symbol {
+	switch {
+	case f.receiver != "":
+		return &Symbol{Kind: "extension", ClassName: f.receiver}
+	case f.owner != nil:
+		return &Symbol{Kind: "method", ClassName: f.owner.name}
+	case fd.scopeAt(f.nameTok) == fd.root:
+		return &Symbol{Kind: "top-level", FQN: qualify(fd.pkg, f.name)}
+	}
+	return &Symbol{Kind: "method", FileLocal: true} // a local function
+}
+
+func propSymbol(fd *fileDecls, p *propDecl) *Symbol {
+	switch {
+	case p.receiver != "":
+		return &Symbol{Kind: "extension", ClassName: p.receiver}
+	case p.local:
+		return &Symbol{Kind: "local", FileLocal: true}
+	case p.owner != nil:
+		return &Symbol{Kind: "property", ClassName: p.owner.name}
+	}
+	return &Symbol{Kind: "top-level", FQN: qualify(fd.pkg, p.name)}
+}
+
+func typeParamSymbol(tp *typeParamDecl) *Symbol {
+	sym := &Symbol{Kind: "type-parameter", FileLocal: true}
+	switch {
+	case tp.fn != nil:
+		sym.Function = tp.fn.name
+		if tp.fn.owner != nil {
+			sym.ClassName = tp.fn.owner.name
+		}
+	case tp.class != nil:
+		sym.ClassName = tp.class.name
+	case tp.alias != nil:
+		sym.ClassName = tp.alias.name
+	}
+	return sym
+}
+
+// paramTok reports whether code[i] names one of f's parameters.
+func paramTok(f *funcDecl, i int) bool {
+	for _, prm := range f.params {
+		if prm.nameTok == i {
+			return true
+		}
+	}
+	return false
+}
+
+// ─── references ───────────────────────────────────────────────────────────────
+
+// referenceAt resolves the identifier at code[i], which is not itself a
+// declaration, to the declaration it refers to.
+func (ix *Index) referenceAt(fd *fileDecls, i int) (*Symbol, error) {
+	name := unquoteIdent(fd.code[i].text)
+	pos := fd.code[i].start
+	where := positionOf(fd, pos)
+
+	// import com.example.Name, com.example.Name.member, or a qualified name
+	if imp, ok := importAt(fd.imports, pos); ok || ix.packageQualified(fd.src, pos) {
+		qualifier, _ := qualifierBefore(fd.src, pos)
+		if !ok || imp.wildcard || pos+len(name) <= imp.pathEnd {
+			if dfd, tok := ix.declOf(qualify(qualifier, name)); dfd != nil {
+				return ix.declarationAt(dfd, tok), nil
+			}
+		}
+		return nil, fmt.Errorf("%s at %s is not declared in the scanned files", qualify(qualifier, name), where)
+	}
+	if _, label := fd.namedArgCallee(i); label {
+		return nil, fmt.Errorf("%s at %s is a named argument; rename the parameter at its declaration", name, where)
+	}
+
+	p := &parser{fd: fd, code: fd.code}
+	call := p.text(i+1) == "(" || p.text(i+1) == "{"
+	access, r := fd.receiverEnd(i)
+	if access == accessDot || access == accessReference {
+		types, ok := fd.superTypes(r)
+		if !ok {
+			typ, known := fd.typeOfReceiver(r)
+			if !known {
+				return nil, fmt.Errorf("cannot infer the receiver type of %s at %s", name, where)
+			}
+			types = []string{typ}
+		}
+		for _, typ := range types {
+			if dfd, tok := ix.memberDecl(typ, name, call || access == accessReference); dfd != nil {
+				return ix.declarationAt(dfd, tok), nil
+			}
+		}
+		return nil, fmt.Errorf("%s has no member %s in the scanned files", strings.Join(types, "/"), name)
+	}
+
+	// locals, parameters and properties in scope
+	if b := fd.lookup(name, i); b != nil {
+		return ix.declarationAt(fd, b.tok), nil
+	}
+	if name == "it" {
+		if s := fd.itLambda(i); s != nil {
+			return &Symbol{Kind: "local", FileLocal: true, Package: fd.pkg, Decl: positionOf(fd, fd.code[s.open].start)}, nil
+		}
+	}
+	if tp := fd.typeParamAt(pos, name); tp != nil {
+		return ix.declarationAt(fd, tp.nameTok), nil
+	}
+
+	// members of enclosing classes, through this
+	for c := fd.classAt(i); c != nil; c = c.outer {
+		if dfd, tok := ix.memberDecl(c.name, name, call); dfd != nil {
+			return ix.declarationAt(dfd, tok), nil
+		}
+	}
+	for _, f := range fd.funcs {
+		if f.name == name && f.owner == nil && fd.scopeAt(f.nameTok) != fd.root && fd.scopeAt(f.nameTok).contains(i) {
+			return ix.declarationAt(fd, f.nameTok), nil // a local function
+		}
+	}
+
+	// classifiers, top-level declarations and enum entries brought in by
+	// imports or the file's package
+	if dfd, tok := ix.visibleDecl(fd, name); dfd != nil {
+		return ix.declarationAt(dfd, tok), nil
+	}
+	return nil, fmt.Errorf("cannot tell what %s at %s refers to", name, where)
+}
+
+// packageQualified reports whether the identifier at pos is qualified by a
+// package some scanned file belongs to, as in com.example.User.
+func (ix *Index) packageQualified(src string, pos int) bool {
+	qualifier, ok := qualifierBefore(src, pos)
+	if !ok {
+		return false
+	}
+	for _, fd := range ix.files {
+		if fd.pkg == qualifier {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleDecl finds the declaration the unqualified name refers to through
+// the file's explicit imports, its package and its wildcard imports.
+func (ix *Index) visibleDecl(fd *fileDecls, name string) (*fileDecls, int) {
+	for _, imp := range fd.imports {
+		if !imp.wildcard && imp.name() == name {
+			return ix.declOf(imp.path)
+		}
+	}
+	if dfd, tok := ix.declOf(qualify(fd.pkg, name)); dfd != nil {
+		return dfd, tok
+	}
+	for _, imp := range fd.imports {
+		if imp.wildcard {
+			if dfd, tok := ix.declOf(imp.path + "." + name); dfd != nil {
+				return dfd, tok
+			}
+		}
+	}
+	return nil, -1
+}
+
+// declOf finds the declaration with the fully-qualified name fqn: a top-level
+// class, typealias, function or property, an extension, or a member of a
+// class (an enum entry, nested class or object member).
+func (ix *Index) declOf(fqn string) (*fileDecls, int) {
+	pkg, name := splitFQN(fqn)
+	for _, path := range sortedKeys(ix.pathSet()) {
+		fd := ix.files[path]
+		if fd.pkg != pkg {
+			continue
+		}
+		for _, c := range fd.classes {
+			if c.outer == nil && c.name == name {
+				return fd, c.nameTok
+			}
+		}
+		for _, a := range fd.aliases {
+			if a.name == name {
+				return fd, a.nameTok
+			}
+		}
+		for _, f := range fd.funcs {
+			if f.name == name && f.owner == nil && fd.scopeAt(f.nameTok) == fd.root {
+				return fd, f.nameTok
+			}
+		}
+		for _, p := range fd.props {
+			if p.name == name && p.owner == nil && !p.local {
+				return fd, p.nameTok
+			}
+		}
+	}
+
+	// a member of the class ownerFQN
+	ownerPkg, owner := splitFQN(pkg)
+	for _, path := range sortedKeys(ix.pathSet()) {
+		fd := ix.files[path]
+		for _, c := range fd.classes {
+			if c.name != owner || (c.outer == nil && fd.pkg != ownerPkg) {
+				continue
+			}
+			for _, e := range c.entries {
+				if unquoteIdent(fd.code[e].text) == name {
+					return fd, e
+				}
+			}
+			for _, nested := range fd.classes {
+				if nested.outer == c && nested.name == name {
+					return fd, nested.nameTok
+				}
+			}
+			if tok := memberTok(c, name, false); tok >= 0 {
+				return fd, tok
+			}
+		}
+	}
+	return nil, -1
+}
+
+// memberDecl finds the member called name of the class typ or its nearest
+// supertype declaring one, preferring a function when call is true and a
+// property otherwise, then an extension on typ or a supertype, then an enum
+// entry or nested class of typ.
+func (ix *Index) memberDecl(typ, name string, call bool) (*fileDecls, int) {
+	seen := make(map[string]bool)
+	queue := []string{typ}
+	for len(queue) > 0 {
+		cls := queue[0]
+		queue = queue[1:]
+		if seen[cls] {
+			continue
+		}
+		seen[cls] = true
+		for _, path := range sortedKeys(ix.pathSet()) {
+			fd := ix.files[path]
+			for _, c := range fd.classes {
+				if c.name != cls {
+					continue
+				}
+				if tok := memberTok(c, name, call); tok >= 0 {
+					return fd, tok
+				}
+				queue = append(queue, c.supertypes...)
+			}
+		}
+	}
+
+	for _, path := range sortedKeys(ix.pathSet()) {
+		fd := ix.files[path]
+		for _, f := range fd.funcs {
+			if f.name == name && f.receiver != "" && ix.family(f.receiver)[typ] {
+				return fd, f.nameTok
+			}
+		}
+		for _, p := range fd.props {
+			if p.name == name && p.receiver != "" && ix.family(p.receiver)[typ] {
+				return fd, p.nameTok
+			}
+		}
+		for _, c := range fd.classes {
+			if c.name != typ {
+				continue
+			}
+			for _, e := range c.entries {
+				if unquoteIdent(fd.code[e].text) == name {
+					return fd, e
+				}
+			}
+			for _, nested := range fd.classes {
+				if nested.outer == c && nested.name == name {
+					return fd, nested.nameTok
+				}
+			}
+		}
+	}
+	return nil, -1
+}
+
+// memberTok returns the name token of c's member function or property
+// called name, preferring a function when call is true, or -1.
+func memberTok(c *classDecl, name string, call bool) int {
+	fn, prop := -1, -1
+	for _, f := range c.funcs {
+		if f.name == name && f.receiver == "" && fn < 0 {
+			fn = f.nameTok
+		}
+	}
+	for _, p := range c.props {
+		if p.name == name && p.receiver == "" && prop < 0 {
+			prop = p.nameTok
+		}
+	}
+	if call && fn >= 0 || prop < 0 {
+		return fn
+	}
+	return prop
+}
+
+func (ix *Index) pathSet() map[string]bool {
+	paths := make(map[string]bool, len(ix.files))
+	for path := range ix.files {
+		paths[path] = true
+	}
+	return paths
+}
+
+// ─── positions ────────────────────────────────────────────────────────────────
+
+// positionOf returns the line and column of byte offset pos in fd.
+func positionOf(fd *fileDecls, pos int) Position {
+	lineStart := strings.LastIndexByte(fd.src[:pos], '\n') + 1
+	return Position{
+		Path:   fd.path,
+		Line:   strings.Count(fd.src[:pos], "\n") + 1,
+		Column: utf8.RuneCountInString(fd.src[lineStart:pos]) + 1,
+	}
+}
+
+// offsetOf returns the byte offset of line:column in src.
+func offsetOf(src string, line, column int) (int, bool) {
+	lo, hi, ok := lineSpan(src, line)
+	if !ok || column < 1 {
+		return 0, false
+	}
+	pos := lo
+	for n := 1; n < column; n++ {
+		if pos >= hi {
+			return 0, false
+		}
+		_, size := utf8.DecodeRuneInString(src[pos:])
+		pos += size
+	}
+	return pos, true
+}
+
+// qualify joins a package and a simple name.
+func qualify(pkg, name string) string {
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
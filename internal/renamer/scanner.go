@@ -11,9 +11,22 @@ type FileResult struct {
 	Path         string
 	Replacements int
 	NewContent   string // only populated when changes exist
+	Warnings     []Warning
 	Err          error
 }
 
+// Warning flags an occurrence that was deliberately left unchanged because
+// the renamer could not tell whether it refers to the target symbol.
+type Warning struct {
+	Line    int
+	Message string
+}
+
+// RenameFunc rewrites the content of one file and returns the new content,
+// the number of replacements, and any warnings about occurrences it left
+// alone.
+type RenameFunc func(content string) (string, int, []Warning)
+
 // ScanOptions controls which files are considered.
 type ScanOptions struct {
 	// ProjectRoot scans all .kt files recursively under this directory.
@@ -70,7 +83,7 @@ func CollectKotlinFiles(opts ScanOptions) ([]string, error) {
 
 // ApplyToFiles runs renameFn over each file path, collecting results.
 // If dryRun is false, modified files are written back.
-func ApplyToFiles(paths []string, dryRun bool, renameFn func(content string) (string, int)) ([]FileResult, error) {
+func ApplyToFiles(paths []string, dryRun bool, renameFn RenameFunc) ([]FileResult, error) {
 	results := make([]FileResult, 0, len(paths))
 
 	for _, path := range paths {
@@ -81,9 +94,12 @@ func ApplyToFiles(paths []string, dryRun bool, renameFn func(content string) (st
 		}
 
 		original := string(raw)
-		modified, count := renameFn(original)
+		modified, count, warnings := renameFn(original)
 
 		if count == 0 {
+			if len(warnings) > 0 {
+				results = append(results, FileResult{Path: path, Warnings: warnings})
+			}
 			continue // nothing changed in this file
 		}
 
@@ -91,6 +107,7 @@ func ApplyToFiles(paths []string, dryRun bool, renameFn func(content string) (st
 			Path:         path,
 			Replacements: count,
 			NewContent:   modified,
+			Warnings:     warnings,
 		}
 
 		if !dryRun {
@@ -0,0 +1,212 @@
+package renamer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Signature is the list of parameter types that identifies one overload of
+// a function, e.g. (Long, String).  Types are compared by simple name, so
+// "com.example.UserId?" and "UserId" are the same parameter type.
+type Signature []string
+
+// ParseSignature parses a parameter type list such as "(Long, String)".
+// The parentheses are optional; "()" is the signature of a function without
+// parameters and yields an empty, non-nil Signature.
+func ParseSignature(s string) (Signature, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") && closingParen(s, 0) == len(s)-1 {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	sig := Signature{}
+	if s == "" {
+		return sig, nil
+	}
+
+	depth, start := 0, 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			switch s[i] {
+			case '<', '(':
+				depth++
+				continue
+			case '>', ')':
+				if s[i] == '>' && i > 0 && s[i-1] == '-' {
+					continue // the arrow of a function type
+				}
+				depth--
+				if depth < 0 {
+					return nil, fmt.Errorf("invalid signature %q: unbalanced brackets", s)
+				}
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		typ := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s[start:i]), "vararg "))
+		if typ == "" {
+			return nil, fmt.Errorf("invalid signature %q: empty parameter type", s)
+		}
+		sig = append(sig, normalizeType(typ))
+		start = i + 1
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("invalid signature %q: unbalanced brackets", s)
+	}
+	return sig, nil
+}
+
+// signatureOf returns the parameter types of f.
+func signatureOf(f *funcDecl) Signature {
+	sig := Signature{}
+	for _, prm := range f.params {
+		sig = append(sig, normalizeType(prm.typ))
+	}
+	return sig
+}
+
+// matches reports whether f declares exactly these parameter types.
+func (s Signature) matches(f *funcDecl) bool {
+	other := signatureOf(f)
+	if len(other) != len(s) {
+		return false
+	}
+	for i := range s {
+		if s[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Signature) String() string {
+	return "(" + strings.Join(s, ", ") + ")"
+}
+
+// normalizeType reduces a written parameter type to the form signatures are
+// compared in: its simple name, or a function type without blanks.
+func normalizeType(typ string) string {
+	return strings.Join(strings.Fields(simpleTypeName(typ)), "")
+}
+
+// ─── call sites ───────────────────────────────────────────────────────────────
+
+// callArg is one argument at a call site.
+type callArg struct {
+	label string // the name of a named argument, "" for a positional one
+	typ   string // simple type name when obvious from the source, else ""
+}
+
+// callArgs returns the arguments of the call whose argument list (or
+// trailing lambda) opens at code[open].  ok is false when code[open] opens
+// neither.
+func (fd *fileDecls) callArgs(open int) (args []callArg, ok bool) {
+	if open >= len(fd.code) {
+		return nil, false
+	}
+	p := &parser{fd: fd, code: fd.code}
+	lambda := open
+	if fd.code[open].text == "(" {
+		close := matchingToken(fd.code, open)
+		if close < 0 {
+			return nil, false
+		}
+		for _, seg := range splitTopLevel(fd.code, open+1, close) {
+			from, to := seg[0], seg[1]
+			if from >= to {
+				continue
+			}
+			var arg callArg
+			if fd.code[from].kind == tokIdent && p.text(from+1) == "=" && p.text(from+2) != "=" {
+				arg.label = unquoteIdent(fd.code[from].text)
+				from += 2
+			}
+			switch {
+			case to-from == 1 && fd.code[from].kind == tokIdent:
+				if b := fd.lookup(unquoteIdent(fd.code[from].text), from); b != nil {
+					arg.typ = b.typ
+				} else {
+					arg.typ = p.inferExprType(from, to)
+				}
+			case to-from == 1 || fd.code[from].kind == tokIdent:
+				arg.typ = p.inferExprType(from, to)
+			}
+			args = append(args, arg)
+		}
+		lambda = close + 1
+	}
+	if p.text(lambda) == "{" {
+		args = append(args, callArg{typ: "(lambda)"})
+	} else if lambda == open {
+		return nil, false
+	}
+	return args, true
+}
+
+// accepts reports whether a call passing args may resolve to f, judging by
+// the number of arguments, the labels of named ones and the obvious types
+// of the rest.
+func (f *funcDecl) accepts(args []callArg) bool {
+	if !f.acceptsArgs(len(args)) {
+		return false
+	}
+	named := false
+	for k, arg := range args {
+		var prm *paramDecl
+		switch {
+		case arg.label != "":
+			named = true
+			if prm = f.param(arg.label); prm == nil {
+				return false
+			}
+		case named || k >= len(f.params):
+			continue // a trailing lambda or vararg element
+		default:
+			prm = f.params[k]
+		}
+		if arg.typ != "(lambda)" && !assignable(arg.typ, normalizeType(prm.typ)) {
+			return false
+		}
+	}
+	return true
+}
+
+// builtinTypes are final types: a parameter of one of them accepts nothing
+// else.  Any other parameter type may accept a subtype, which kr cannot
+// tell.
+var builtinTypes = map[string]bool{
+	"String": true, "Char": true, "Boolean": true, "Int": true, "Long": true,
+	"Short": true, "Byte": true, "Double": true, "Float": true, "UInt": true,
+}
+
+// assignable reports whether an argument of type arg may be passed for a
+// parameter of type param.  Unknown types are assumed to fit.
+func assignable(arg, param string) bool {
+	if arg == "" || param == "" || arg == param || !builtinTypes[param] {
+		return true
+	}
+	// an integer literal adapts to the expected integer type
+	return arg == "Int" && (param == "Long" || param == "Short" || param == "Byte")
+}
+
+// closingParen returns the index of the ")" matching the "(" at s[open], or
+// -1.
+func closingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,140 @@
+package renamer
+
+// TopLevelRenamer renames a top-level function or property, identified by
+// its fully-qualified name, e.g. com.example.util.formatDate.
+//
+// Contexts handled:
+//   - declaration:      fun formatDate(  /  val DEFAULT_TIMEOUT  (every
+//     top-level declaration of the name in the package, overloads included)
+//   - import:           import com.example.util.formatDate [as alias]
+//   - qualified use:    com.example.util.formatDate(...)
+//   - unqualified use:  formatDate(...) / ::formatDate / DEFAULT_TIMEOUT in
+//     files that import it explicitly or by wildcard, or that belong to the
+//     same package
+//
+// An unqualified use is skipped when a local, parameter, member or another
+// import of the same name is closer.  Aliased imports keep their alias, so
+// code using the alias is left alone.  An import that also brings in a
+// same-named extension from the package is kept, and an import of the new
+// name is added after it.
+type TopLevelRenamer struct {
+	MatchOptions
+	FQN   string // fully-qualified name of the declaration
+	Index *Index // optional: tells whether the package declares same-named extensions
+	reporter
+}
+
+func (r *TopLevelRenamer) Rename(content, oldName, newName string) (string, int) {
+	r.reset()
+	fd := parseFile(content)
+	pkg, _ := splitFQN(r.FQN)
+	pkgs := map[string]bool{pkg: true}
+	shared := map[string]bool{pkg: r.sharesName(fd, oldName)}
+	visible := r.visible(fd, pkg, oldName)
+
+	out, n := singlePassRename(content, oldName, newName, r.MatchOptions, func(src string, start, end int) bool {
+		if start > 0 && isIdentChar(src[start-1]) || end < len(src) && isIdentChar(src[end]) {
+			return false
+		}
+		if imp, ok := importAt(fd.imports, start); ok {
+			return importsMember(imp, start, oldName, pkgs) && !shared[pkg]
+		}
+		if qualifier, ok := qualifierBefore(src, start); ok {
+			return qualifier == pkg
+		}
+		return r.refersToDeclaration(fd, pkg, visible, start, oldName)
+	})
+	if n == 0 || !shared[pkg] {
+		return out, n
+	}
+	for _, imp := range fd.imports {
+		if importsMember(imp, imp.nameStart, oldName, pkgs) && imp.alias == "" {
+			out = addImportAfter(out, content[imp.start:imp.end], pkg+"."+newName)
+		}
+	}
+	return out, n
+}
+
+// sharesName reports whether the package also declares a top-level
+// extension called name, which an import of the name brings in too.
+func (r *TopLevelRenamer) sharesName(fd *fileDecls, name string) bool {
+	pkg, _ := splitFQN(r.FQN)
+	files := map[string]*fileDecls{"": fd}
+	if r.Index != nil {
+		files = r.Index.files
+	}
+	for _, f := range files {
+		if f.pkg == pkg && declaresTopLevel(f, name, func(receiver string) bool { return receiver != "" }) {
+			return true
+		}
+	}
+	return false
+}
+
+// visible reports whether the simple name refers to the declaration in fd
+// as far as imports and packages go.  Kotlin resolves it through explicit
+// imports first, then the file's own package, then wildcard imports.
+func (r *TopLevelRenamer) visible(fd *fileDecls, pkg, name string) bool {
+	for _, imp := range fd.imports {
+		if !imp.wildcard && imp.name() == name {
+			return imp.path == r.FQN
+		}
+	}
+	if fd.pkg == pkg {
+		return true
+	}
+	for _, imp := range fd.imports {
+		if imp.wildcard && imp.path == pkg {
+			return !declaresTopLevel(fd, name, func(string) bool { return true })
+		}
+	}
+	return false
+}
+
+// refersToDeclaration decides whether the unqualified occurrence at byte
+// offset pos refers to the top-level declaration.
+func (r *TopLevelRenamer) refersToDeclaration(fd *fileDecls, pkg string, visible bool, pos int, name string) bool {
+	i := fd.codeIndex(pos)
+	if i < 0 || fd.code[i].kind != tokIdent {
+		return visible
+	}
+
+	// declarations
+	if f := fd.funcAt(i); f != nil {
+		return fd.pkg == pkg && f.receiver == "" && f.owner == nil && fd.scopeAt(i) == fd.root
+	}
+	for _, p := range fd.props {
+		if p.nameTok == i {
+			return fd.pkg == pkg && p.receiver == "" && p.owner == nil && !p.local
+		}
+	}
+	if !visible {
+		return false
+	}
+
+	if _, label := fd.namedArgCallee(i); label {
+		return false
+	}
+	switch access, _ := fd.receiverEnd(i); access {
+	case accessDot, accessReference:
+		return false
+	}
+
+	// a local, parameter, member property or member function is closer
+	if b := fd.lookup(name, i); b != nil {
+		return b.prop != nil && b.prop.owner == nil && !b.prop.local && b.prop.receiver == ""
+	}
+	for c := fd.classAt(i); c != nil; c = c.outer {
+		if declaresFunc(c, name) {
+			return false
+		}
+	}
+	for s := fd.scopeAt(i); s != nil; s = s.parent {
+		for _, f := range fd.funcs {
+			if f.name == name && f.owner == nil && s != fd.root && s.contains(f.nameTok) {
+				return false // a local function
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package renamer
+
+// TypeAliasRenamer renames a typealias, identified by its fully-qualified
+// name, e.g. com.example.UserId.
+//
+// Contexts handled:
+//   - declaration:      typealias UserId = String
+//   - import:           import com.example.UserId [as alias]
+//   - qualified use:    com.example.UserId
+//   - unqualified use:  val id: UserId / List<UserId> / x as UserId / UserId(...)
+//     in files that import it explicitly or by wildcard, or that belong to
+//     the same package
+//
+// Functions, properties, parameters and classes that merely share the name
+// are left alone, as are member accesses such as request.UserId.
+type TypeAliasRenamer struct {
+	MatchOptions
+	FQN string // fully-qualified name of the typealias
+}
+
+func (r *TypeAliasRenamer) Rename(content, oldName, newName string) (string, int) {
+	fd := parseFile(content)
+	pkg, _ := splitFQN(r.FQN)
+	pkgs := map[string]bool{pkg: true}
+	visible := resolvesToClass(content, fd.imports, r.FQN)
+
+	return singlePassRename(content, oldName, newName, r.MatchOptions, func(src string, start, end int) bool {
+		if start > 0 && isIdentChar(src[start-1]) || end < len(src) && isIdentChar(src[end]) {
+			return false
+		}
+		if imp, ok := importAt(fd.imports, start); ok {
+			return importsMember(imp, start, oldName, pkgs)
+		}
+		if qualifier, ok := qualifierBefore(src, start); ok {
+			return qualifier == pkg
+		}
+		return refersToAlias(fd, pkg, visible, start)
+	})
+}
+
+// refersToAlias decides whether the unqualified occurrence at byte offset
+// pos refers to the typealias declared in pkg.
+func refersToAlias(fd *fileDecls, pkg string, visible bool, pos int) bool {
+	i := fd.codeIndex(pos)
+	if i < 0 || fd.code[i].kind != tokIdent {
+		return visible
+	}
+	for _, a := range fd.aliases {
+		if a.nameTok == i {
+			return fd.pkg == pkg
+		}
+	}
+	if declaresName(fd, i) {
+		return false
+	}
+	return visible
+}
+
+// declaresName reports whether code[i] is the name in some declaration:
+// a class, function, property, parameter or type parameter.
+func declaresName(fd *fileDecls, i int) bool {
+	for _, c := range fd.classes {
+		if c.nameTok == i {
+			return true
+		}
+	}
+	if fd.funcAt(i) != nil {
+		return true
+	}
+	for _, p := range fd.props {
+		if p.nameTok == i {
+			return true
+		}
+	}
+	for _, tp := range fd.typeParams {
+		if tp.nameTok == i {
+			return true
+		}
+	}
+	b := fd.lookup(unquoteIdent(fd.code[i].text), i)
+	return b != nil && b.tok == i
+}
@@ -0,0 +1,99 @@
+package renamer
+
+// TypeParameterRenamer renames a type parameter within the class, function
+// or typealias that declares it.
+//
+// Contexts handled, all inside the declaration:
+//   - declaration:    class Box<T>  /  fun <T> first()  /  typealias Pred<T>
+//   - upper bounds:   <T : Comparable<T>>  /  where T : Any
+//   - signature:      (items: List<T>): T
+//   - body:           val x: T  /  x as T  /  T::class
+//
+// Every declaration with a type parameter of that name is renamed unless
+// narrowed by Function and ClassName.  A nested declaration with its own
+// type parameter of the same name shadows the outer one, and nested classes
+// that are not inner cannot see their outer class's type parameters, so
+// neither is touched.
+type TypeParameterRenamer struct {
+	MatchOptions
+	Function  string // optional: only type parameters of functions with this name
+	ClassName string // optional: only type parameters of this class or typealias, or with Function, of its member functions
+}
+
+func (r *TypeParameterRenamer) Rename(content, oldName, newName string) (string, int) {
+	fd := parseFile(content)
+
+	var starts []int
+	for _, tp := range fd.typeParams {
+		if tp.name != oldName || !r.isTarget(tp) {
+			continue
+		}
+		lo, hi := fd.code[tp.from].start, fd.code[tp.to].end
+		starts = append(starts, findMatches(content, fd.toks, oldName, r.MatchOptions, lo, hi, func(src string, start, end int) bool {
+			if start > 0 && isIdentChar(src[start-1]) || end < len(src) && isIdentChar(src[end]) {
+				return false
+			}
+			return fd.typeParamAt(start, oldName) == tp
+		})...)
+	}
+	return replaceAt(content, sortedUnique(starts), oldName, newName)
+}
+
+func (r *TypeParameterRenamer) isTarget(tp *typeParamDecl) bool {
+	switch {
+	case r.Function != "":
+		return tp.fn != nil && tp.fn.name == r.Function &&
+			(r.ClassName == "" || tp.fn.owner != nil && tp.fn.owner.name == r.ClassName)
+	case r.ClassName != "":
+		return tp.class != nil && tp.class.name == r.ClassName || tp.alias != nil && tp.alias.name == r.ClassName
+	}
+	return true
+}
+
+// typeParamAt returns the type parameter that the occurrence of name at byte
+// offset pos refers to, or nil.
+func (fd *fileDecls) typeParamAt(pos int, name string) *typeParamDecl {
+	i := fd.codeIndex(pos)
+	if i >= 0 {
+		for _, tp := range fd.typeParams {
+			if tp.nameTok == i {
+				return tp
+			}
+		}
+		if _, ok := qualifierBefore(fd.src, pos); ok || declaresName(fd, i) {
+			return nil
+		}
+	}
+
+	// the innermost declaration in scope wins
+	var best *typeParamDecl
+	for _, tp := range fd.typeParams {
+		if tp.name == name && fd.code[tp.from].start <= pos && pos < fd.code[tp.to].end &&
+			(best == nil || tp.from > best.from) {
+			best = tp
+		}
+	}
+	if best == nil || best.class == nil {
+		return best
+	}
+
+	// a nested class that is not inner starts a new type-parameter scope
+	for _, c := range fd.classes {
+		start := c.nameTok
+		if start < 0 {
+			start = c.bodyOpen // companion object { ... }
+		}
+		if c == best.class || c.name == "" || start <= best.from || hasString(c.modifiers, "inner") {
+			continue
+		}
+		end := c.headerEnd
+		if c.bodyClose > end {
+			end = c.bodyClose
+		}
+		if s := fd.scopeAt(start); s.kind == scopeClass &&
+			fd.code[start].start <= pos && pos <= fd.code[end].end {
+			return nil
+		}
+	}
+	return best
+}